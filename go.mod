@@ -5,6 +5,6 @@
 
 module github.com/AnimeKaizoku/ssg
 
-go 1.18
+go 1.23
 
 require golang.org/x/text v0.9.0
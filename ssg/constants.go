@@ -126,3 +126,65 @@ const (
 const (
 	LIST_INDEX_NOTFOUND = -1
 )
+
+const (
+	// DefaultShardCount is the count of the shards used by ShardedSafeMap
+	// when no valid shard count is specified.
+	DefaultShardCount = 32
+
+	// expiryBatchSize is the maximum count of the expired values which
+	// SafeEMap removes while holding its lock, before releasing it for a moment.
+	expiryBatchSize = 512
+)
+
+const (
+	// EvictionPolicyLRU evicts the least recently used value.
+	EvictionPolicyLRU EvictionPolicy = iota
+	// EvictionPolicyLFU evicts the least frequently used value; between the
+	// values with the same frequency, the least recently used one is evicted.
+	EvictionPolicyLFU
+)
+
+const (
+	// MapEventAdd is the kind of the events of adding a new key to a map.
+	MapEventAdd MapEventKind = iota
+	// MapEventUpdate is the kind of the events of changing the value of an
+	// existing key of a map.
+	MapEventUpdate
+	// MapEventDelete is the kind of the events of deleting a key from a map.
+	MapEventDelete
+	// MapEventClear is the kind of the events of clearing a map.
+	MapEventClear
+	// MapEventExpire is the kind of the events of removing an expired value
+	// from an expiring map.
+	MapEventExpire
+)
+
+const (
+	// EventDeliverySync calls the handlers in the goroutine which has changed
+	// the map, after the lock of the map is released.
+	EventDeliverySync EventDeliveryMode = iota
+	// EventDeliveryPool calls the handlers in a bounded pool of goroutines;
+	// the order of the events is not guaranteed.
+	EventDeliveryPool
+	// EventDeliveryBuffered calls the handlers in a single goroutine, in the
+	// order of the events, through a buffered queue.
+	EventDeliveryBuffered
+)
+
+const (
+	// EventDropNewest drops the new event if the queue is full.
+	EventDropNewest EventDropPolicy = iota
+	// EventDropOldest drops the oldest event of the queue to make room for
+	// the new event.
+	EventDropOldest
+	// EventBlock blocks the goroutine which has changed the map until there
+	// is room in the queue.
+	EventBlock
+)
+
+const (
+	// DefaultEventBufferSize is the size of the event queues when no valid
+	// size is specified.
+	DefaultEventBufferSize = 64
+)
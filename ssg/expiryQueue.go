@@ -0,0 +1,32 @@
+package ssg
+
+func (q expiryQueue[TKey, TValue]) Len() int {
+	return len(q)
+}
+
+func (q expiryQueue[TKey, TValue]) Less(i, j int) bool {
+	return q[i].deadline < q[j].deadline
+}
+
+func (q expiryQueue[TKey, TValue]) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].value._index = i
+	q[j].value._index = j
+}
+
+func (q *expiryQueue[TKey, TValue]) Push(x any) {
+	item := x.(*expiryItem[TKey, TValue])
+	item.value._index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *expiryQueue[TKey, TValue]) Pop() any {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	// don't keep the popped item alive in the underlying array.
+	old[n-1] = nil
+	item.value._index = -1
+	*q = old[:n-1]
+	return item
+}
@@ -6,6 +6,8 @@
 package ssg
 
 import (
+	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -397,18 +399,200 @@ func GetListFromArray[T comparable](array []T) GenericList[T] {
 	return &ListW[T]{array}
 }
 
+// NewSafeList returns a new empty thread safe list.
+func NewSafeList[T comparable]() *SafeList[T] {
+	return &SafeList[T]{
+		mut: &sync.RWMutex{},
+	}
+}
+
+// GetSafeListFromArray returns a new thread safe list containing a copy
+// of the given array. changes made to the array after this call won't be
+// applied to the list.
+func GetSafeListFromArray[T comparable](array []T) *SafeList[T] {
+	values := make([]T, len(array))
+	copy(values, array)
+	return newSafeListFromArray(values)
+}
+
+// newSafeListFromArray returns a new thread safe list which uses the given
+// array itself, so the array shouldn't be used by the caller anymore.
+func newSafeListFromArray[T comparable](array []T) *SafeList[T] {
+	return &SafeList[T]{
+		mut:     &sync.RWMutex{},
+		_values: array,
+	}
+}
+
+// newListLike returns a new list containing the given values; the new list
+// is thread safe only if the original list is.
+func newListLike[T, TResult comparable](list GenericList[T], values []TResult) GenericList[TResult] {
+	if _, ok := list.(*SafeList[T]); ok {
+		return newSafeListFromArray(values)
+	}
+
+	return &ListW[TResult]{values}
+}
+
+// MapList returns a new list containing the result of calling fn for each
+// of the elements of the list, in the same order.
+// the new list is thread safe only if the original list is.
+func MapList[T, TResult comparable](list GenericList[T], fn func(element T) TResult) GenericList[TResult] {
+	if list == nil || fn == nil {
+		return GetEmptyList[TResult]()
+	}
+
+	array := list.AsArray()
+	values := make([]TResult, len(array))
+	for i, current := range array {
+		values[i] = fn(current)
+	}
+
+	return newListLike(list, values)
+}
+
+// Reduce calls fn for each of the elements of the list, passing the result
+// of the previous call (or initial for the first element) to it, and
+// returns the result of the last call.
+func Reduce[T comparable, TResult any](
+	list GenericList[T],
+	initial TResult,
+	fn func(result TResult, element T) TResult,
+) TResult {
+	if list == nil || fn == nil {
+		return initial
+	}
+
+	result := initial
+	for _, current := range list.AsArray() {
+		result = fn(result, current)
+	}
+
+	return result
+}
+
+// GroupBy groups the elements of the list by the key returned by keyGetter.
+// the elements of each group keep their order in the original list; the
+// groups are thread safe only if the original list is.
+func GroupBy[T, TKey comparable](
+	list GenericList[T],
+	keyGetter func(element T) TKey,
+) map[TKey]GenericList[T] {
+	groups := make(map[TKey]GenericList[T])
+	if list == nil || keyGetter == nil {
+		return groups
+	}
+
+	values := make(map[TKey][]T)
+	var keys []TKey
+	for _, current := range list.AsArray() {
+		key := keyGetter(current)
+		if _, exists := values[key]; !exists {
+			keys = append(keys, key)
+		}
+
+		values[key] = append(values[key], current)
+	}
+
+	for _, key := range keys {
+		groups[key] = newListLike(list, values[key])
+	}
+
+	return groups
+}
+
 func NewEValue[T any](value T) *ExpiringValue[T] {
 	return &ExpiringValue[T]{
 		_value: value,
-		_t:     time.Now(),
+		_t:     time.Now().UnixNano(),
+		_index: -1,
 	}
 }
 
+// NewEValueWithTTL returns a new expiring value which has its own lifetime,
+// instead of using the default expiration of its container.
+func NewEValueWithTTL[T any](value T, ttl time.Duration) *ExpiringValue[T] {
+	e := NewEValue(value)
+	e._ttl = ttl
+	return e
+}
+
 func NewSafeMap[TKey comparable, TValue any]() *SafeMap[TKey, TValue] {
 	return &SafeMap[TKey, TValue]{
 		mut:    &sync.RWMutex{},
 		values: make(map[TKey]*TValue),
+		events: newMapEvents[TKey, TValue](),
+	}
+}
+
+// NewShardedSafeMap returns a new ShardedSafeMap with the given shard count,
+// using DefaultShardHasher for choosing the shard of each key.
+// if shardCount is not positive, DefaultShardCount will be used.
+func NewShardedSafeMap[TKey comparable, TValue any](shardCount int) *ShardedSafeMap[TKey, TValue] {
+	return NewShardedSafeMapWithHasher[TKey, TValue](shardCount, DefaultShardHasher[TKey])
+}
+
+// NewShardedSafeMapWithHasher returns a new ShardedSafeMap with the given
+// shard count and hasher function.
+// if shardCount is not positive, DefaultShardCount will be used, and if hasher
+// is nil, DefaultShardHasher will be used.
+func NewShardedSafeMapWithHasher[TKey comparable, TValue any](
+	shardCount int,
+	hasher ShardHasher[TKey],
+) *ShardedSafeMap[TKey, TValue] {
+	if shardCount <= 0 {
+		shardCount = DefaultShardCount
+	}
+
+	if hasher == nil {
+		hasher = DefaultShardHasher[TKey]
+	}
+
+	m := &ShardedSafeMap[TKey, TValue]{
+		shards: make([]*SafeMap[TKey, TValue], shardCount),
+		hasher: hasher,
+	}
+
+	for i := range m.shards {
+		m.shards[i] = NewSafeMap[TKey, TValue]()
+	}
+
+	return m
+}
+
+// DefaultShardHasher is the default hasher used by ShardedSafeMap.
+// it hashes strings and integers without any allocation; other key types are
+// hashed using their string representation, which is slower, so you may want
+// to pass your own hasher for them.
+func DefaultShardHasher[TKey comparable](key TKey) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return hashString(k)
+	case int:
+		return mixHash(uint64(k))
+	case int8:
+		return mixHash(uint64(k))
+	case int16:
+		return mixHash(uint64(k))
+	case int32:
+		return mixHash(uint64(k))
+	case int64:
+		return mixHash(uint64(k))
+	case uint:
+		return mixHash(uint64(k))
+	case uint8:
+		return mixHash(uint64(k))
+	case uint16:
+		return mixHash(uint64(k))
+	case uint32:
+		return mixHash(uint64(k))
+	case uint64:
+		return mixHash(k)
+	case uintptr:
+		return mixHash(uint64(k))
 	}
+
+	return hashString(fmt.Sprint(key))
 }
 
 func NewAdvancedMap[TKey comparable, TValue any]() *AdvancedMap[TKey, TValue] {
@@ -416,14 +600,73 @@ func NewAdvancedMap[TKey comparable, TValue any]() *AdvancedMap[TKey, TValue] {
 		mut:           &sync.Mutex{},
 		values:        make(map[TKey]*TValue),
 		sliceKeyIndex: make(map[TKey]int),
+		events:        newMapEvents[TKey, TValue](),
+	}
+}
+
+// NewLRUMap returns a new LRUMap which evicts the least recently used value
+// when it has more than maxEntries values.
+// if maxEntries is not positive, the map won't have any limit.
+func NewLRUMap[TKey comparable, TValue any](maxEntries int) *LRUMap[TKey, TValue] {
+	return NewLRUMapWithPolicy[TKey, TValue](maxEntries, EvictionPolicyLRU)
+}
+
+// NewLFUMap returns a new LRUMap which evicts the least frequently used value
+// when it has more than maxEntries values.
+// if maxEntries is not positive, the map won't have any limit.
+func NewLFUMap[TKey comparable, TValue any](maxEntries int) *LRUMap[TKey, TValue] {
+	return NewLRUMapWithPolicy[TKey, TValue](maxEntries, EvictionPolicyLFU)
+}
+
+// NewLRUMapWithPolicy returns a new LRUMap with the given eviction policy.
+// if maxEntries is not positive, the map won't have any limit.
+func NewLRUMapWithPolicy[TKey comparable, TValue any](
+	maxEntries int,
+	policy EvictionPolicy,
+) *LRUMap[TKey, TValue] {
+	if maxEntries < 0 {
+		maxEntries = 0
+	}
+
+	return &LRUMap[TKey, TValue]{
+		mut:        &sync.Mutex{},
+		values:     make(map[TKey]*lruEntry[TKey, TValue]),
+		queue:      lruQueue[TKey, TValue]{policy: policy},
+		maxEntries: maxEntries,
+		policy:     policy,
 	}
 }
 
 func NewSafeEMap[TKey comparable, TValue any]() *SafeEMap[TKey, TValue] {
 	return &SafeEMap[TKey, TValue]{
 		mut:           &sync.RWMutex{},
+		checkerMut:    &sync.Mutex{},
 		values:        make(map[TKey]*ExpiringValue[*TValue]),
 		sliceKeyIndex: make(map[TKey]int),
+		sliding:       true,
+		wakeChan:      make(chan struct{}, 1),
+		events:        newMapEvents[TKey, TValue](),
+	}
+}
+
+// newMapEvents returns a new event container, delivering the events
+// synchronously.
+func newMapEvents[TKey comparable, TValue any]() *mapEvents[TKey, TValue] {
+	return &mapEvents[TKey, TValue]{
+		mut: &sync.RWMutex{},
+		options: MapEventOptions{
+			Mode:       EventDeliverySync,
+			BufferSize: DefaultEventBufferSize,
+		},
+		watchers: make(map[*mapWatcher[TKey, TValue]]bool),
+	}
+}
+
+func newEventQueue[TKey comparable, TValue any](size int) *eventQueue[TKey, TValue] {
+	return &eventQueue[TKey, TValue]{
+		mut:     &sync.RWMutex{},
+		channel: make(chan MapEvent[TKey, TValue], size),
+		done:    make(chan struct{}),
 	}
 }
 
@@ -535,6 +778,28 @@ func repairString(value *string) *string {
 	return &final
 }
 
+// hashString returns the FNV-1a hash of the given string.
+func hashString(value string) uint64 {
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(value); i++ {
+		hash ^= uint64(value[i])
+		hash *= 1099511628211
+	}
+
+	return hash
+}
+
+// mixHash spreads the bits of the given integer, so sequential integers
+// won't end up in sequential shards.
+func mixHash(value uint64) uint64 {
+	value ^= value >> 33
+	value *= 0xff51afd7ed558ccd
+	value ^= value >> 33
+	value *= 0xc4ceb9fe1a85ec53
+	value ^= value >> 33
+	return value
+}
+
 func isSpecial(r rune) bool {
 	switch r {
 	case EqualChar, DPointChar:
@@ -543,3 +808,63 @@ func isSpecial(r rune) bool {
 		return false
 	}
 }
+
+// filterArray returns a new array containing the elements of the array
+// for which fn returns true.
+func filterArray[T any](array []T, fn func(T) bool) []T {
+	var values []T
+	if fn == nil {
+		return values
+	}
+
+	for _, current := range array {
+		if fn(current) {
+			values = append(values, current)
+		}
+	}
+
+	return values
+}
+
+// distinctArray returns a new array containing the first occurrence of each
+// of the elements of the array.
+func distinctArray[T comparable](array []T) []T {
+	seen := make(map[T]bool, len(array))
+	values := make([]T, 0, len(array))
+	for _, current := range array {
+		if seen[current] {
+			continue
+		}
+
+		seen[current] = true
+		values = append(values, current)
+	}
+
+	return values
+}
+
+// sliceArray returns a copy of array[from:to]; the bounds are clamped to
+// the length of the array.
+func sliceArray[T any](array []T, from, to int) []T {
+	from = max(from, 0)
+	to = min(to, len(array))
+	if from >= to {
+		return nil
+	}
+
+	return slices.Clone(array[from:to])
+}
+
+// chunkArray splits the array into arrays of at most `size` elements.
+func chunkArray[T any](array []T, size int) [][]T {
+	if size <= 0 {
+		return nil
+	}
+
+	var chunks [][]T
+	for chunk := range slices.Chunk(array, size) {
+		chunks = append(chunks, slices.Clone(chunk))
+	}
+
+	return chunks
+}
@@ -1,6 +1,9 @@
 package internal
 
 import (
+	"io"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 )
@@ -110,3 +113,44 @@ func Contains[T comparable](slice []T, target T) bool {
 
 	return false
 }
+
+// WriteFileAtomic writes the file with the given write function; the data is
+// written to a temporary file in the same directory first, which is then
+// renamed to the path, so the file is never left half-written.
+// the mode of the existing file is kept; perm is used for the new files.
+func WriteFileAtomic(path string, perm os.FileMode, write func(w io.Writer) error) error {
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	tmpName := file.Name()
+	err = write(file)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tmpName, perm)
+	}
+
+	if err == nil {
+		err = os.Rename(tmpName, path)
+	}
+
+	if err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package ssg
+
+import "iter"
+
+// the iterators of the thread-safe collections are based on snapshots: the
+// elements are copied while holding the lock, when the iteration starts, and
+// the lock is released before calling the loop body. so the body can safely
+// use (and modify) the collection itself, but the changes made during the
+// iteration won't be seen by it.
+// each iteration takes a new snapshot, so an iterator can be reused.
+
+// snapshotAll returns an iterator over the key-value pairs returned by snapshot.
+func snapshotAll[TKey comparable, TValue any](
+	snapshot func() ([]TKey, []*TValue),
+) iter.Seq2[TKey, *TValue] {
+	return func(yield func(TKey, *TValue) bool) {
+		keys, values := snapshot()
+		for i, key := range keys {
+			if !yield(key, values[i]) {
+				return
+			}
+		}
+	}
+}
+
+// snapshotKeys returns an iterator over the keys returned by snapshot.
+func snapshotKeys[TKey comparable, TValue any](
+	snapshot func() ([]TKey, []*TValue),
+) iter.Seq[TKey] {
+	return func(yield func(TKey) bool) {
+		keys, _ := snapshot()
+		for _, key := range keys {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// snapshotValues returns an iterator over the values returned by snapshot.
+func snapshotValues[TKey comparable, TValue any](
+	snapshot func() ([]TKey, []*TValue),
+) iter.Seq[*TValue] {
+	return func(yield func(*TValue) bool) {
+		_, values := snapshot()
+		for _, value := range values {
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,583 @@
+package ssg
+
+import (
+	"container/heap"
+	"iter"
+	"math/rand"
+	"time"
+)
+
+func (s *LRUMap[TKey, TValue]) lock() {
+	s.mut.Lock()
+}
+
+func (s *LRUMap[TKey, TValue]) unlock() {
+	s.mut.Unlock()
+}
+
+// isExpired returns true if the given entry is expired.
+func (s *LRUMap[TKey, TValue]) isExpired(entry *lruEntry[TKey, TValue], now time.Time) bool {
+	return s.expiration > 0 && now.Sub(entry.addedAt) > s.expiration
+}
+
+// touch marks the entry as used; the caller should hold the lock.
+func (s *LRUMap[TKey, TValue]) touch(entry *lruEntry[TKey, TValue]) {
+	s.clock++
+	entry.lastAccess = s.clock
+	entry.frequency++
+	heap.Fix(&s.queue, entry.index)
+}
+
+// getEntry returns the entry of the given key, removing it if it's expired.
+// the caller should hold the lock; the returned event function (if not nil)
+// should be called after releasing the lock.
+func (s *LRUMap[TKey, TValue]) getEntry(key TKey) (*lruEntry[TKey, TValue], func()) {
+	entry := s.values[key]
+	if entry == nil {
+		return nil, nil
+	}
+
+	if s.isExpired(entry, time.Now()) {
+		s.remove(entry)
+		return nil, s.getEvent(s.onExpired, entry)
+	}
+
+	return entry, nil
+}
+
+// remove removes the entry from the map; the caller should hold the lock.
+func (s *LRUMap[TKey, TValue]) remove(entry *lruEntry[TKey, TValue]) {
+	heap.Remove(&s.queue, entry.index)
+	delete(s.values, entry.key)
+}
+
+// getRandomEntry returns a random entry which is not expired, or nil if there
+// is none. the expired entries which are chosen are removed, so it's still
+// uniform over the remaining entries. the caller should hold the lock; the
+// returned event functions should be called after releasing it.
+func (s *LRUMap[TKey, TValue]) getRandomEntry() (*lruEntry[TKey, TValue], []func()) {
+	var events []func()
+	now := time.Now()
+	for len(s.queue.entries) != 0 {
+		entry := s.queue.entries[rand.Intn(len(s.queue.entries))]
+		if !s.isExpired(entry, now) {
+			return entry, events
+		}
+
+		s.remove(entry)
+		if event := s.getEvent(s.onExpired, entry); event != nil {
+			events = append(events, event)
+		}
+	}
+
+	return nil, events
+}
+
+// evict removes the entries which have to be evicted until the map has at
+// most `limit` values; the expired entries are removed first, then the
+// entries chosen by the policy. the caller should hold the lock.
+func (s *LRUMap[TKey, TValue]) evict(limit int) []func() {
+	if s.maxEntries == 0 || len(s.values) <= limit {
+		return nil
+	}
+
+	_, events := s.removeExpired(time.Now())
+	for len(s.values) > limit {
+		entry := s.queue.entries[0]
+		s.remove(entry)
+		if event := s.getEvent(s.onEvicted, entry); event != nil {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+// removeExpired removes the expired entries and returns their count; the
+// caller should hold the lock, and call the returned event functions after
+// releasing it.
+func (s *LRUMap[TKey, TValue]) removeExpired(now time.Time) (int, []func()) {
+	if s.expiration <= 0 {
+		return 0, nil
+	}
+
+	var events []func()
+	count := 0
+	for _, entry := range s.values {
+		if !s.isExpired(entry, now) {
+			continue
+		}
+
+		s.remove(entry)
+		count++
+		if event := s.getEvent(s.onExpired, entry); event != nil {
+			events = append(events, event)
+		}
+	}
+
+	return count, events
+}
+
+// getEvent returns a function which calls the given event for the entry,
+// or nil if the event is not set.
+func (s *LRUMap[TKey, TValue]) getEvent(event func(TKey, TValue), entry *lruEntry[TKey, TValue]) func() {
+	if event == nil {
+		return nil
+	}
+
+	key := entry.key
+	value := s.getRealValue(entry.value)
+	return func() {
+		event(key, value)
+	}
+}
+
+func (s *LRUMap[TKey, TValue]) getRealValue(value *TValue) TValue {
+	if value == nil {
+		return s._default
+	}
+
+	return *value
+}
+
+// Exists returns true if the key exists in the map and it's not expired.
+// it doesn't count as a use of the value.
+func (s *LRUMap[TKey, TValue]) Exists(key TKey) bool {
+	s.lock()
+	entry := s.values[key]
+	b := entry != nil && !s.isExpired(entry, time.Now())
+	s.unlock()
+	return b
+}
+
+// Add adds the value to the map; if the map is full, values will be evicted
+// according to its eviction policy.
+// the events of the evicted values are called synchronously, after releasing
+// the lock of the map.
+func (s *LRUMap[TKey, TValue]) Add(key TKey, value *TValue) {
+	s.lock()
+	entry := s.values[key]
+	if entry != nil {
+		entry.value = value
+		entry.addedAt = time.Now()
+		s.touch(entry)
+		s.unlock()
+		return
+	}
+
+	// make room for the new value before adding it, otherwise the new value
+	// itself would be the first one to be evicted in LFU policy.
+	events := s.evict(s.maxEntries - 1)
+
+	s.clock++
+	entry = &lruEntry[TKey, TValue]{
+		key:        key,
+		value:      value,
+		frequency:  1,
+		lastAccess: s.clock,
+		addedAt:    time.Now(),
+	}
+	s.values[key] = entry
+	heap.Push(&s.queue, entry)
+	s.unlock()
+
+	for _, event := range events {
+		event()
+	}
+}
+
+// Set function sets the key of type TKey in this safe map to the value.
+// the value should be of type TValue or *TValue, otherwise this function won't
+// do anything at all.
+func (s *LRUMap[TKey, TValue]) Set(key TKey, value any) {
+	correctValue, ok := value.(*TValue)
+	if !ok {
+		anotherValue, ok := value.(TValue)
+		if !ok {
+			return
+		}
+
+		correctValue = &anotherValue
+	}
+
+	s.Add(key, correctValue)
+}
+
+func (s *LRUMap[TKey, TValue]) AddList(keyGetter func(*TValue) TKey, elements ...TValue) {
+	if len(elements) == 0 || keyGetter == nil {
+		return
+	}
+
+	for _, current := range elements {
+		s.Add(keyGetter(&current), &current)
+	}
+}
+
+func (s *LRUMap[TKey, TValue]) AddPointerList(keyGetter func(*TValue) TKey, elements ...*TValue) {
+	if len(elements) == 0 || keyGetter == nil {
+		return
+	}
+
+	for _, current := range elements {
+		s.Add(keyGetter(current), current)
+	}
+}
+
+// Get returns the value of the key and marks it as used.
+// it returns nil if the key doesn't exist or it's expired.
+func (s *LRUMap[TKey, TValue]) Get(key TKey) *TValue {
+	s.lock()
+	entry, event := s.getEntry(key)
+	var value *TValue
+	if entry != nil {
+		s.hits++
+		s.touch(entry)
+		value = entry.value
+	} else {
+		s.misses++
+	}
+	s.unlock()
+
+	if event != nil {
+		event()
+	}
+
+	return value
+}
+
+func (s *LRUMap[TKey, TValue]) GetValue(key TKey) TValue {
+	return s.getRealValue(s.Get(key))
+}
+
+// Peek returns the value of the key without marking it as used, and without
+// affecting the hit/miss counters of the map.
+func (s *LRUMap[TKey, TValue]) Peek(key TKey) *TValue {
+	s.lock()
+	entry := s.values[key]
+	var value *TValue
+	if entry != nil && !s.isExpired(entry, time.Now()) {
+		value = entry.value
+	}
+	s.unlock()
+
+	return value
+}
+
+func (s *LRUMap[TKey, TValue]) Delete(key TKey) {
+	s.lock()
+	entry := s.values[key]
+	if entry != nil {
+		s.remove(entry)
+	}
+	s.unlock()
+}
+
+// ForEach calls fn for each of the non-expired values of the map; returning
+// true from fn will delete the key from the map.
+// fn is called over a snapshot of the map, so the lock isn't held while
+// running it; it doesn't count as a use of the values.
+func (s *LRUMap[TKey, TValue]) ForEach(fn func(TKey, *TValue) bool) {
+	if fn == nil {
+		return
+	}
+
+	s.lock()
+	now := time.Now()
+	entries := make([]*lruEntry[TKey, TValue], 0, len(s.values))
+	for _, entry := range s.values {
+		if !s.isExpired(entry, now) {
+			entries = append(entries, entry)
+		}
+	}
+	s.unlock()
+
+	var removed []*lruEntry[TKey, TValue]
+	for _, entry := range entries {
+		if fn(entry.key, entry.value) {
+			removed = append(removed, entry)
+		}
+	}
+
+	if len(removed) == 0 {
+		return
+	}
+
+	s.lock()
+	for _, entry := range removed {
+		// the key may have been set again in the meantime.
+		if s.values[entry.key] == entry {
+			s.remove(entry)
+		}
+	}
+	s.unlock()
+}
+
+// All returns an iterator over the key-value pairs of the map which are not
+// expired. the iteration is done over a snapshot of the map, so the lock
+// isn't held while running the loop body; like Peek, it doesn't change the
+// recency or the frequency of the entries.
+func (s *LRUMap[TKey, TValue]) All() iter.Seq2[TKey, *TValue] {
+	return snapshotAll(s.snapshot)
+}
+
+// Keys returns an iterator over a snapshot of the keys of the map.
+func (s *LRUMap[TKey, TValue]) Keys() iter.Seq[TKey] {
+	return snapshotKeys(s.snapshot)
+}
+
+// Values returns an iterator over a snapshot of the values of the map.
+func (s *LRUMap[TKey, TValue]) Values() iter.Seq[*TValue] {
+	return snapshotValues(s.snapshot)
+}
+
+// snapshot returns a copy of the keys and the values of the entries which
+// are not expired.
+func (s *LRUMap[TKey, TValue]) snapshot() ([]TKey, []*TValue) {
+	s.lock()
+	now := time.Now()
+	keys := make([]TKey, 0, len(s.values))
+	values := make([]*TValue, 0, len(s.values))
+	for key, entry := range s.values {
+		if s.isExpired(entry, now) {
+			continue
+		}
+
+		keys = append(keys, key)
+		values = append(values, entry.value)
+	}
+	s.unlock()
+
+	return keys, values
+}
+
+// GetRandom returns a random value of the map which is not expired; the
+// expired values which are chosen on the way are removed, like in Get.
+func (s *LRUMap[TKey, TValue]) GetRandom() *TValue {
+	s.lock()
+	entry, events := s.getRandomEntry()
+	s.unlock()
+
+	for _, event := range events {
+		event()
+	}
+
+	if entry == nil {
+		return nil
+	}
+
+	return entry.value
+}
+
+func (s *LRUMap[TKey, TValue]) GetRandomValue() TValue {
+	return s.getRealValue(s.GetRandom())
+}
+
+// GetRandomKey returns a random key of the map which is not expired.
+func (s *LRUMap[TKey, TValue]) GetRandomKey() (key TKey, ok bool) {
+	s.lock()
+	entry, events := s.getRandomEntry()
+	s.unlock()
+
+	for _, event := range events {
+		event()
+	}
+
+	if entry == nil {
+		return
+	}
+
+	return entry.key, true
+}
+
+func (s *LRUMap[TKey, TValue]) ToArray() []TValue {
+	var array []TValue
+	s.ForEach(func(_ TKey, value *TValue) bool {
+		array = append(array, s.getRealValue(value))
+		return false
+	})
+
+	return array
+}
+
+func (s *LRUMap[TKey, TValue]) ToPointerArray() []*TValue {
+	var array []*TValue
+	s.ForEach(func(_ TKey, value *TValue) bool {
+		if value != nil {
+			array = append(array, value)
+		}
+		return false
+	})
+
+	return array
+}
+
+func (s *LRUMap[TKey, TValue]) ToList() GenericList[*TValue] {
+	return GetListFromArray(s.ToPointerArray())
+}
+
+func (s *LRUMap[TKey, TValue]) ToNormalMap() map[TKey]TValue {
+	m := make(map[TKey]TValue)
+	s.ForEach(func(key TKey, value *TValue) bool {
+		m[key] = s.getRealValue(value)
+		return false
+	})
+
+	return m
+}
+
+func (s *LRUMap[TKey, TValue]) SetDefault(value TValue) {
+	s._default = value
+}
+
+// Clear will clear the whole map. it doesn't reset the hit/miss counters.
+func (s *LRUMap[TKey, TValue]) Clear() {
+	s.lock()
+	if len(s.values) != 0 {
+		s.values = make(map[TKey]*lruEntry[TKey, TValue])
+		s.queue.entries = nil
+	}
+	s.unlock()
+}
+
+// Length returns the count of the values of the map. expired values are
+// removed lazily, so they may still be counted until RemoveExpired is called
+// or they are accessed.
+func (s *LRUMap[TKey, TValue]) Length() int {
+	s.lock()
+	l := len(s.values)
+	s.unlock()
+
+	return l
+}
+
+func (s *LRUMap[TKey, TValue]) IsEmpty() bool {
+	return s.Length() == 0
+}
+
+func (s *LRUMap[TKey, TValue]) IsThreadSafe() bool {
+	return true
+}
+
+func (s *LRUMap[TKey, TValue]) IsValid() bool {
+	return s.Length() > 0
+}
+
+// SetMaxEntries sets the maximum count of the values of the map; values will
+// be evicted if the map has more values than that. zero means no limit.
+func (s *LRUMap[TKey, TValue]) SetMaxEntries(maxEntries int) {
+	if maxEntries < 0 {
+		maxEntries = 0
+	}
+
+	s.lock()
+	s.maxEntries = maxEntries
+	events := s.evict(maxEntries)
+	s.unlock()
+
+	for _, event := range events {
+		event()
+	}
+}
+
+func (s *LRUMap[TKey, TValue]) GetMaxEntries() int {
+	s.lock()
+	defer s.unlock()
+
+	return s.maxEntries
+}
+
+func (s *LRUMap[TKey, TValue]) GetPolicy() EvictionPolicy {
+	return s.policy
+}
+
+// SetExpiration sets the lifetime of the values of the map; zero means the
+// values never expire. expired values are removed lazily, when they are
+// accessed or when RemoveExpired is called.
+func (s *LRUMap[TKey, TValue]) SetExpiration(duration time.Duration) {
+	s.lock()
+	s.expiration = duration
+	s.unlock()
+}
+
+// RemoveExpired removes all of the expired values from the map and returns
+// the count of the removed values.
+func (s *LRUMap[TKey, TValue]) RemoveExpired() int {
+	s.lock()
+	count, events := s.removeExpired(time.Now())
+	s.unlock()
+
+	for _, event := range events {
+		event()
+	}
+
+	return count
+}
+
+// SetOnEvicted sets the event function which will be called when a value is
+// evicted because the map is full.
+func (s *LRUMap[TKey, TValue]) SetOnEvicted(event func(key TKey, value TValue)) {
+	s.lock()
+	s.onEvicted = event
+	s.unlock()
+}
+
+// SetOnExpired sets the event function which will be called when an expired
+// value is removed from the map.
+func (s *LRUMap[TKey, TValue]) SetOnExpired(event func(key TKey, value TValue)) {
+	s.lock()
+	s.onExpired = event
+	s.unlock()
+}
+
+// GetStats returns the count of the hits and misses of the Get method.
+func (s *LRUMap[TKey, TValue]) GetStats() (hits, misses uint64) {
+	s.lock()
+	defer s.unlock()
+
+	return s.hits, s.misses
+}
+
+// ResetStats resets the hit/miss counters of the map.
+func (s *LRUMap[TKey, TValue]) ResetStats() {
+	s.lock()
+	s.hits = 0
+	s.misses = 0
+	s.unlock()
+}
+
+//---------------------------------------------------------
+
+func (q lruQueue[TKey, TValue]) Len() int {
+	return len(q.entries)
+}
+
+func (q lruQueue[TKey, TValue]) Less(i, j int) bool {
+	a, b := q.entries[i], q.entries[j]
+	if q.policy == EvictionPolicyLFU && a.frequency != b.frequency {
+		return a.frequency < b.frequency
+	}
+
+	return a.lastAccess < b.lastAccess
+}
+
+func (q lruQueue[TKey, TValue]) Swap(i, j int) {
+	q.entries[i], q.entries[j] = q.entries[j], q.entries[i]
+	q.entries[i].index = i
+	q.entries[j].index = j
+}
+
+func (q *lruQueue[TKey, TValue]) Push(x any) {
+	entry := x.(*lruEntry[TKey, TValue])
+	entry.index = len(q.entries)
+	q.entries = append(q.entries, entry)
+}
+
+func (q *lruQueue[TKey, TValue]) Pop() any {
+	n := len(q.entries)
+	entry := q.entries[n-1]
+	// don't keep the popped entry alive in the underlying array.
+	q.entries[n-1] = nil
+	entry.index = -1
+	q.entries = q.entries[:n-1]
+	return entry
+}
@@ -0,0 +1,346 @@
+package ssg
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// isActive returns true if there is at least one handler or watcher.
+func (e *mapEvents[TKey, TValue]) isActive() bool {
+	return atomic.LoadInt32(&e.active) != 0
+}
+
+func (e *mapEvents[TKey, TValue]) OnAdd(fn func(key TKey, value TValue)) {
+	if fn == nil {
+		return
+	}
+
+	e.mut.Lock()
+	e.onAdd = append(e.onAdd, fn)
+	atomic.AddInt32(&e.active, 1)
+	e.mut.Unlock()
+}
+
+func (e *mapEvents[TKey, TValue]) OnUpdate(fn func(key TKey, oldValue, value TValue)) {
+	if fn == nil {
+		return
+	}
+
+	e.mut.Lock()
+	e.onUpdate = append(e.onUpdate, fn)
+	atomic.AddInt32(&e.active, 1)
+	e.mut.Unlock()
+}
+
+func (e *mapEvents[TKey, TValue]) OnDelete(fn func(key TKey, value TValue)) {
+	if fn == nil {
+		return
+	}
+
+	e.mut.Lock()
+	e.onDelete = append(e.onDelete, fn)
+	atomic.AddInt32(&e.active, 1)
+	e.mut.Unlock()
+}
+
+func (e *mapEvents[TKey, TValue]) OnClear(fn func()) {
+	if fn == nil {
+		return
+	}
+
+	e.mut.Lock()
+	e.onClear = append(e.onClear, fn)
+	atomic.AddInt32(&e.active, 1)
+	e.mut.Unlock()
+}
+
+// SetOnExpire replaces the handler of the expire events; passing nil removes it.
+func (e *mapEvents[TKey, TValue]) SetOnExpire(fn func(key TKey, value TValue)) {
+	e.mut.Lock()
+	if e.onExpire == nil && fn != nil {
+		atomic.AddInt32(&e.active, 1)
+	} else if e.onExpire != nil && fn == nil {
+		atomic.AddInt32(&e.active, -1)
+	}
+	e.onExpire = fn
+	e.mut.Unlock()
+}
+
+// Watch returns a channel which receives all of the events, until the
+// context is done; the channel is closed after that.
+func (e *mapEvents[TKey, TValue]) Watch(ctx context.Context) <-chan MapEvent[TKey, TValue] {
+	e.mut.Lock()
+	watcher := &mapWatcher[TKey, TValue]{
+		mut:     &sync.Mutex{},
+		ctx:     ctx,
+		channel: make(chan MapEvent[TKey, TValue], e.options.BufferSize),
+	}
+	e.watchers[watcher] = true
+	atomic.AddInt32(&e.active, 1)
+	e.mut.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		e.mut.Lock()
+		delete(e.watchers, watcher)
+		atomic.AddInt32(&e.active, -1)
+		e.mut.Unlock()
+
+		// a blocked sender stops waiting, since the context is done.
+		watcher.close()
+	}()
+
+	return watcher.channel
+}
+
+// SetOptions changes the delivery options of the events. the goroutines
+// of the previous asynchronous mode (if any) exit after delivering the
+// events which are already in their queue; the events which are waiting
+// for room in that queue are dropped.
+func (e *mapEvents[TKey, TValue]) SetOptions(options MapEventOptions) {
+	if options.BufferSize <= 0 {
+		options.BufferSize = DefaultEventBufferSize
+	}
+
+	switch options.Mode {
+	case EventDeliveryPool:
+		if options.Workers <= 0 {
+			options.Workers = 1
+		}
+	case EventDeliveryBuffered:
+		options.Workers = 1
+	default:
+		options.Workers = 0
+	}
+
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	if e.queue != nil {
+		e.queue.close()
+		e.queue = nil
+	}
+
+	e.options = options
+	if options.Workers == 0 {
+		return
+	}
+
+	e.queue = newEventQueue[TKey, TValue](options.BufferSize)
+	for i := 0; i < options.Workers; i++ {
+		go e.work(e.queue.channel)
+	}
+}
+
+// GetOptions returns the current delivery options of the events.
+func (e *mapEvents[TKey, TValue]) GetOptions() MapEventOptions {
+	e.mut.RLock()
+	defer e.mut.RUnlock()
+
+	return e.options
+}
+
+// emit delivers the events to the watchers and the handlers.
+// it should be called after releasing the lock of the map, since the handlers
+// may be called synchronously.
+// the events are sent without holding the lock of the events, since sending
+// them may block until the workers (which take that lock) drain the queue.
+func (e *mapEvents[TKey, TValue]) emit(events ...MapEvent[TKey, TValue]) {
+	if len(events) == 0 || !e.isActive() {
+		return
+	}
+
+	e.mut.RLock()
+	policy := e.options.DropPolicy
+	queue := e.queue
+	watchers := make([]*mapWatcher[TKey, TValue], 0, len(e.watchers))
+	for watcher := range e.watchers {
+		watchers = append(watchers, watcher)
+	}
+	e.mut.RUnlock()
+
+	for _, event := range events {
+		for _, watcher := range watchers {
+			watcher.send(event, policy)
+		}
+
+		if queue != nil {
+			queue.send(event, policy)
+		}
+	}
+
+	if queue == nil {
+		for _, event := range events {
+			e.dispatch(event)
+		}
+	}
+}
+
+// dispatch calls the handlers of the event.
+func (e *mapEvents[TKey, TValue]) dispatch(event MapEvent[TKey, TValue]) {
+	e.mut.RLock()
+	var handlers []func(TKey, TValue)
+	var onUpdate []func(TKey, TValue, TValue)
+	var onClear []func()
+	switch event.Kind {
+	case MapEventAdd:
+		handlers = e.onAdd
+	case MapEventUpdate:
+		onUpdate = e.onUpdate
+	case MapEventDelete:
+		handlers = e.onDelete
+	case MapEventClear:
+		onClear = e.onClear
+	case MapEventExpire:
+		if e.onExpire != nil {
+			handlers = []func(TKey, TValue){e.onExpire}
+		}
+	}
+	e.mut.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event.Key, event.Value)
+	}
+
+	for _, handler := range onUpdate {
+		handler(event.Key, event.OldValue, event.Value)
+	}
+
+	for _, handler := range onClear {
+		handler()
+	}
+}
+
+func (e *mapEvents[TKey, TValue]) work(queue chan MapEvent[TKey, TValue]) {
+	for event := range queue {
+		e.dispatch(event)
+	}
+}
+
+//---------------------------------------------------------
+
+func (w *mapWatcher[TKey, TValue]) send(event MapEvent[TKey, TValue], policy EventDropPolicy) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	select {
+	case <-w.ctx.Done():
+		// the channel is going to be closed.
+		return
+	default:
+	}
+
+	sendEvent(w.channel, event, policy, w.ctx.Done())
+}
+
+// close closes the channel of the watcher; its context should be done, so
+// the senders don't keep the lock while waiting for room in the channel.
+func (w *mapWatcher[TKey, TValue]) close() {
+	w.mut.Lock()
+	w.closed = true
+	close(w.channel)
+	w.mut.Unlock()
+}
+
+//---------------------------------------------------------
+
+func (q *eventQueue[TKey, TValue]) send(event MapEvent[TKey, TValue], policy EventDropPolicy) {
+	q.mut.RLock()
+	defer q.mut.RUnlock()
+
+	if q.closed {
+		return
+	}
+
+	sendEvent(q.channel, event, policy, q.done)
+}
+
+// close closes the queue; the workers exit after delivering the events which
+// are already in it.
+func (q *eventQueue[TKey, TValue]) close() {
+	close(q.done)
+
+	q.mut.Lock()
+	q.closed = true
+	close(q.channel)
+	q.mut.Unlock()
+}
+
+// sendEvent sends the event to the channel according to the drop policy.
+// cancel (if not nil) stops the blocking policy from waiting.
+func sendEvent[TKey comparable, TValue any](
+	channel chan MapEvent[TKey, TValue],
+	event MapEvent[TKey, TValue],
+	policy EventDropPolicy,
+	cancel <-chan struct{},
+) {
+	switch policy {
+	case EventBlock:
+		select {
+		case channel <- event:
+		case <-cancel:
+		}
+	case EventDropOldest:
+		for {
+			select {
+			case channel <- event:
+				return
+			default:
+			}
+
+			select {
+			case <-channel:
+			default:
+			}
+		}
+	default:
+		select {
+		case channel <- event:
+		default:
+		}
+	}
+}
+
+// getSetEvent returns the event of setting the key to the value.
+func getSetEvent[TKey comparable, TValue any](
+	key TKey,
+	oldValue, value *TValue,
+	exists bool,
+	def TValue,
+) MapEvent[TKey, TValue] {
+	if !exists {
+		return getValueEvent(MapEventAdd, key, value, def)
+	}
+
+	event := getValueEvent(MapEventUpdate, key, value, def)
+	event.OldValue = getValueOrDefault(oldValue, def)
+	return event
+}
+
+// getValueEvent returns an event of the given kind for the key and value.
+func getValueEvent[TKey comparable, TValue any](
+	kind MapEventKind,
+	key TKey,
+	value *TValue,
+	def TValue,
+) MapEvent[TKey, TValue] {
+	return MapEvent[TKey, TValue]{
+		Kind:  kind,
+		Key:   key,
+		Value: getValueOrDefault(value, def),
+	}
+}
+
+func getValueOrDefault[TValue any](value *TValue, def TValue) TValue {
+	if value == nil {
+		return def
+	}
+
+	return *value
+}
@@ -6,10 +6,15 @@
 package ssg
 
 import (
+	"context"
+	"io"
+	"iter"
 	"math/rand"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -496,6 +501,86 @@ func (l *ListW[T]) Remove(element T) {
 	l.RemoveOnce(element)
 }
 
+// Filter returns a new list containing the elements of this list for which
+// fn returns true.
+func (l *ListW[T]) Filter(fn func(element T) bool) GenericList[T] {
+	return &ListW[T]{filterArray(l._values, fn)}
+}
+
+// Where is equivalent to Filter in any way.
+func (l *ListW[T]) Where(fn func(element T) bool) GenericList[T] {
+	return l.Filter(fn)
+}
+
+// FindFunc returns the index of the first element for which fn returns true,
+// or LIST_INDEX_NOTFOUND if there is no such element.
+func (l *ListW[T]) FindFunc(fn func(element T) bool) int {
+	if fn == nil {
+		return LIST_INDEX_NOTFOUND
+	}
+
+	return slices.IndexFunc(l._values, fn)
+}
+
+// IndexOf is equivalent to Find in any way.
+func (l *ListW[T]) IndexOf(element T) int {
+	return l.Find(element)
+}
+
+// SortFunc returns a new list containing the elements of this list, sorted
+// using cmp (see slices.SortStableFunc). this list itself won't be changed.
+func (l *ListW[T]) SortFunc(cmp func(a, b T) int) GenericList[T] {
+	values := slices.Clone(l._values)
+	if cmp != nil {
+		slices.SortStableFunc(values, cmp)
+	}
+
+	return &ListW[T]{values}
+}
+
+// Distinct returns a new list containing the first occurrence of each of
+// the elements of this list.
+func (l *ListW[T]) Distinct() GenericList[T] {
+	return &ListW[T]{distinctArray(l._values)}
+}
+
+// Reverse returns a new list containing the elements of this list in the
+// reverse order.
+func (l *ListW[T]) Reverse() GenericList[T] {
+	values := slices.Clone(l._values)
+	slices.Reverse(values)
+	return &ListW[T]{values}
+}
+
+// Chunk splits the list into new lists of at most `size` elements.
+// it returns nil if size is not positive.
+func (l *ListW[T]) Chunk(size int) []GenericList[T] {
+	var chunks []GenericList[T]
+	for _, current := range chunkArray(l._values, size) {
+		chunks = append(chunks, &ListW[T]{current})
+	}
+
+	return chunks
+}
+
+// Insert inserts the elements at the specified index, moving the elements
+// after it forward; it doesn't do anything if the index is out of range.
+// unlike the other query methods, it changes the list itself, and returns
+// it for chaining.
+func (l *ListW[T]) Insert(index int, elements ...T) GenericList[T] {
+	if index >= 0 && index <= len(l._values) {
+		l._values = slices.Insert(l._values, index, elements...)
+	}
+
+	return l
+}
+
+// Slice returns a new list containing the elements in the range [from, to)
+// of this list; the range is clamped to the length of the list.
+func (l *ListW[T]) Slice(from, to int) GenericList[T] {
+	return &ListW[T]{sliceArray(l._values, from, to)}
+}
+
 // AsArray returns a copy of the value of this list as an array.
 // please do notice that if you make changes to the underlying values of
 // that array, change won't be applied to the list.
@@ -522,8 +607,36 @@ func (l *ListW[T]) Get(index int) T {
 	return l._values[index]
 }
 
+// All returns an iterator over the indexes and the elements of the list.
+// since ListW doesn't use any lock, the iteration is done over the list
+// itself: the elements appended during the iteration won't be seen, but
+// the elements changed in place will be.
+func (l *ListW[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		for i, current := range l._values {
+			if !yield(i, current) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over the elements of the list.
+// see All for the semantics of the iteration.
+func (l *ListW[T]) Values() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, current := range l._values {
+			if !yield(current) {
+				return
+			}
+		}
+	}
+}
+
+// IsThreadSafe returns false, since ListW doesn't use any lock.
+// use SafeList if you need to share a list between goroutines.
 func (l *ListW[T]) IsThreadSafe() bool {
-	return true
+	return false
 }
 
 func (l *ListW[T]) IsEmpty() bool {
@@ -556,19 +669,26 @@ func (s *AdvancedMap[TKey, TValue]) Exists(key TKey) bool {
 
 func (s *AdvancedMap[TKey, TValue]) Add(key TKey, value *TValue) {
 	s.lock()
-	_, exists := s.values[key]
+	oldValue, exists := s.store(key, value)
+	s.unlock()
+
+	s.events.emit(getSetEvent(key, oldValue, value, exists, s._default))
+}
+
+// store sets the value of the key and returns its previous value.
+// the caller should hold the lock.
+func (s *AdvancedMap[TKey, TValue]) store(key TKey, value *TValue) (*TValue, bool) {
+	oldValue, exists := s.values[key]
 	s.values[key] = value
-	if exists {
-		s.unlock()
-		return
-	}
+	if !exists {
+		s.keys = append(s.keys, key)
 
-	s.keys = append(s.keys, key)
+		// store the index of the map key
+		index := len(s.keys) - 1
+		s.sliceKeyIndex[key] = index
+	}
 
-	// store the index of the map key
-	index := len(s.keys) - 1
-	s.sliceKeyIndex[key] = index
-	s.unlock()
+	return oldValue, exists
 }
 func (s *AdvancedMap[TKey, TValue]) GetRandom() *TValue {
 	if s.IsEmpty() {
@@ -588,15 +708,54 @@ func (s *AdvancedMap[TKey, TValue]) ForEach(fn func(TKey, *TValue) bool) {
 	if fn == nil {
 		return
 	}
+	var events []MapEvent[TKey, TValue]
+	isActive := s.events.isActive()
+
 	s.lock()
 
 	for key, value := range s.values {
 		if fn(key, value) {
 			s.delete(key, false)
+			if isActive {
+				events = append(events, getValueEvent(MapEventDelete, key, value, s._default))
+			}
 		}
 	}
 
 	s.unlock()
+
+	s.events.emit(events...)
+}
+
+// All returns an iterator over the key-value pairs of the map.
+// the iteration is done over a snapshot of the map (in the order of its keys), so the
+// lock isn't held while running the loop body.
+func (s *AdvancedMap[TKey, TValue]) All() iter.Seq2[TKey, *TValue] {
+	return snapshotAll(s.snapshot)
+}
+
+// Keys returns an iterator over a snapshot of the keys of the map.
+func (s *AdvancedMap[TKey, TValue]) Keys() iter.Seq[TKey] {
+	return snapshotKeys(s.snapshot)
+}
+
+// Values returns an iterator over a snapshot of the values of the map.
+func (s *AdvancedMap[TKey, TValue]) Values() iter.Seq[*TValue] {
+	return snapshotValues(s.snapshot)
+}
+
+// snapshot returns a copy of the keys and the values of the map.
+func (s *AdvancedMap[TKey, TValue]) snapshot() ([]TKey, []*TValue) {
+	s.lock()
+	keys := make([]TKey, len(s.keys))
+	copy(keys, s.keys)
+	values := make([]*TValue, len(keys))
+	for i, key := range keys {
+		values[i] = s.values[key]
+	}
+	s.unlock()
+
+	return keys, values
 }
 
 func (s *AdvancedMap[TKey, TValue]) GetRandomValue() TValue {
@@ -698,7 +857,7 @@ func (s *AdvancedMap[TKey, TValue]) AddPointerList(keyGetter func(*TValue) TKey,
 	}
 }
 
-func (s *AdvancedMap[TKey, TValue]) delete(key TKey, useLock bool) {
+func (s *AdvancedMap[TKey, TValue]) delete(key TKey, useLock bool) (*TValue, bool) {
 	if useLock {
 		s.lock()
 	}
@@ -710,7 +869,7 @@ func (s *AdvancedMap[TKey, TValue]) delete(key TKey, useLock bool) {
 			s.unlock()
 		}
 		// item does not exist
-		return
+		return nil, false
 	}
 
 	delete(s.sliceKeyIndex, key)
@@ -728,14 +887,116 @@ func (s *AdvancedMap[TKey, TValue]) delete(key TKey, useLock bool) {
 		s.sliceKeyIndex[otherKey] = index
 	}
 
+	value := s.values[key]
 	delete(s.values, key)
 	if useLock {
 		s.unlock()
 	}
+
+	return value, true
 }
 
 func (s *AdvancedMap[TKey, TValue]) Delete(key TKey) {
-	s.delete(key, true)
+	value, exists := s.delete(key, true)
+	if exists {
+		s.events.emit(getValueEvent(MapEventDelete, key, value, s._default))
+	}
+}
+
+// GetOrAdd returns the existing value of the key if it's present in the map,
+// otherwise it adds the given value and returns it.
+// loaded will be true if the value was already present in the map.
+func (s *AdvancedMap[TKey, TValue]) GetOrAdd(key TKey, value *TValue) (actual *TValue, loaded bool) {
+	return s.GetOrCompute(key, func() *TValue {
+		return value
+	})
+}
+
+// GetOrCompute returns the existing value of the key if it's present in the
+// map, otherwise it adds the value returned by fn and returns it.
+// fn is called at most once, while holding the lock of the map, so it
+// shouldn't use the map itself.
+// loaded will be true if the value was already present in the map.
+func (s *AdvancedMap[TKey, TValue]) GetOrCompute(key TKey, fn func() *TValue) (actual *TValue, loaded bool) {
+	s.lock()
+	actual, loaded = s.values[key]
+	if loaded || fn == nil {
+		s.unlock()
+		return actual, loaded
+	}
+
+	actual = fn()
+	s.store(key, actual)
+	s.unlock()
+
+	s.events.emit(getSetEvent(key, nil, actual, false, s._default))
+	return actual, false
+}
+
+// Compute calls fn with the current value of the key (if any) and stores
+// the value returned by it; if fn returns false as keep, the key is removed
+// from the map instead. the whole operation is done while holding the lock
+// of the map, so fn shouldn't use the map itself.
+// it returns the new value of the key and true if the key is present in the
+// map after the operation.
+func (s *AdvancedMap[TKey, TValue]) Compute(
+	key TKey,
+	fn func(oldValue *TValue, exists bool) (newValue *TValue, keep bool),
+) (*TValue, bool) {
+	if fn == nil {
+		return nil, false
+	}
+
+	s.lock()
+	oldValue, exists := s.values[key]
+	value, keep := fn(oldValue, exists)
+	if !keep {
+		if exists {
+			s.delete(key, false)
+		}
+		s.unlock()
+
+		if exists {
+			s.events.emit(getValueEvent(MapEventDelete, key, oldValue, s._default))
+		}
+		return nil, false
+	}
+
+	s.store(key, value)
+	s.unlock()
+
+	s.events.emit(getSetEvent(key, oldValue, value, exists, s._default))
+	return value, true
+}
+
+// CompareAndSwap replaces the value of the key with newValue, only if the key
+// is present in the map and its current value is oldValue. the values are
+// compared by their pointers.
+// it returns true if the value was swapped.
+func (s *AdvancedMap[TKey, TValue]) CompareAndSwap(key TKey, oldValue, newValue *TValue) bool {
+	s.lock()
+	current, exists := s.values[key]
+	if !exists || current != oldValue {
+		s.unlock()
+		return false
+	}
+
+	s.values[key] = newValue
+	s.unlock()
+
+	s.events.emit(getSetEvent(key, oldValue, newValue, true, s._default))
+	return true
+}
+
+// LoadAndDelete removes the key from the map and returns its previous value.
+// loaded will be false if the key wasn't present in the map.
+func (s *AdvancedMap[TKey, TValue]) LoadAndDelete(key TKey) (value *TValue, loaded bool) {
+	value, loaded = s.delete(key, true)
+	if loaded {
+		s.events.emit(getValueEvent(MapEventDelete, key, value, s._default))
+	}
+
+	return value, loaded
 }
 
 func (s *AdvancedMap[TKey, TValue]) Get(key TKey) *TValue {
@@ -783,8 +1044,12 @@ func (s *AdvancedMap[TKey, TValue]) Clear() {
 	s.lock()
 	if len(s.values) != 0 {
 		s.values = make(map[TKey]*TValue)
+		s.keys = nil
+		s.sliceKeyIndex = make(map[TKey]int)
 	}
 	s.unlock()
+
+	s.events.emit(MapEvent[TKey, TValue]{Kind: MapEventClear})
 }
 
 func (s *AdvancedMap[TKey, TValue]) Length() int {
@@ -823,14 +1088,95 @@ func (s *AdvancedMap[TKey, TValue]) IsValid() bool {
 	return s.Length() > 0
 }
 
+// SaveTo saves the values of the map to the writer, using DefaultSnapshotCodec.
+func (s *AdvancedMap[TKey, TValue]) SaveTo(w io.Writer) error {
+	return s.SaveToWithCodec(w, DefaultSnapshotCodec)
+}
+
+// SaveToWithCodec saves the values of the map to the writer, using the given
+// codec.
+func (s *AdvancedMap[TKey, TValue]) SaveToWithCodec(w io.Writer, codec SnapshotCodec) error {
+	s.lock()
+	snapshot := &MapSnapshot[TKey, TValue]{
+		Entries: make([]SnapshotEntry[TKey, TValue], 0, len(s.values)),
+	}
+	for key, value := range s.values {
+		snapshot.Entries = append(snapshot.Entries, SnapshotEntry[TKey, TValue]{
+			Key:   key,
+			Value: value,
+		})
+	}
+	s.unlock()
+
+	return codec.Encode(w, snapshot)
+}
+
+// LoadFrom loads the values saved by SaveTo into the map, using
+// DefaultSnapshotCodec. the existing keys of the map are overwritten, but
+// the other keys are kept.
+func (s *AdvancedMap[TKey, TValue]) LoadFrom(r io.Reader) error {
+	return s.LoadFromWithCodec(r, DefaultSnapshotCodec)
+}
+
+// LoadFromWithCodec loads the values saved by SaveTo into the map, using the
+// given codec. the existing keys of the map are overwritten, but the other
+// keys are kept.
+func (s *AdvancedMap[TKey, TValue]) LoadFromWithCodec(r io.Reader, codec SnapshotCodec) error {
+	snapshot := new(MapSnapshot[TKey, TValue])
+	err := codec.Decode(r, snapshot)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range snapshot.Entries {
+		s.Add(entry.Key, entry.Value)
+	}
+
+	return nil
+}
+
+// OnAdd registers a handler which is called when a new key is added to the map.
+func (s *AdvancedMap[TKey, TValue]) OnAdd(fn func(key TKey, value TValue)) {
+	s.events.OnAdd(fn)
+}
+
+// OnUpdate registers a handler which is called when the value of an existing
+// key is replaced.
+func (s *AdvancedMap[TKey, TValue]) OnUpdate(fn func(key TKey, oldValue, value TValue)) {
+	s.events.OnUpdate(fn)
+}
+
+// OnDelete registers a handler which is called when a key is removed from
+// the map.
+func (s *AdvancedMap[TKey, TValue]) OnDelete(fn func(key TKey, value TValue)) {
+	s.events.OnDelete(fn)
+}
+
+// OnClear registers a handler which is called when the map is cleared.
+func (s *AdvancedMap[TKey, TValue]) OnClear(fn func()) {
+	s.events.OnClear(fn)
+}
+
+// Watch returns a channel which receives the events of the map until the
+// context is done.
+func (s *AdvancedMap[TKey, TValue]) Watch(ctx context.Context) <-chan MapEvent[TKey, TValue] {
+	return s.events.Watch(ctx)
+}
+
+// SetEventOptions changes how the events of the map are delivered to the
+// handlers and the watchers.
+func (s *AdvancedMap[TKey, TValue]) SetEventOptions(options MapEventOptions) {
+	s.events.SetOptions(options)
+}
+
 //---------------------------------------------------------
 
 func (e *ExpiringValue[T]) SetTime(t time.Time) {
-	e._t = t
+	atomic.StoreInt64(&e._t, t.UnixNano())
 }
 
 func (e *ExpiringValue[T]) GetTime() time.Time {
-	return e._t
+	return time.Unix(0, atomic.LoadInt64(&e._t))
 }
 
 func (e *ExpiringValue[T]) Reset() {
@@ -838,18 +1184,54 @@ func (e *ExpiringValue[T]) Reset() {
 }
 
 func (e *ExpiringValue[T]) IsExpired(duration time.Duration) bool {
-	return time.Since(e._t) > duration
+	return time.Since(e.GetTime()) > duration
+}
+
+// SetTTL sets the lifetime of this specific value. passing zero will make
+// the value use the default expiration of its container again.
+func (e *ExpiringValue[T]) SetTTL(ttl time.Duration) {
+	e._ttl = ttl
+}
+
+// GetTTL returns the lifetime of this specific value; zero means that the
+// default expiration of the container is used for it.
+func (e *ExpiringValue[T]) GetTTL() time.Duration {
+	return e._ttl
+}
+
+// GetRemainingTime returns the remaining lifetime of this value. the given
+// duration is used as the lifetime only if the value has no TTL of its own.
+// it returns zero if the value is already expired.
+func (e *ExpiringValue[T]) GetRemainingTime(duration time.Duration) time.Duration {
+	if e._ttl > 0 {
+		duration = e._ttl
+	}
+
+	remaining := duration - time.Since(e.GetTime())
+	if remaining < 0 {
+		return 0
+	}
+
+	return remaining
 }
 
 func (e *ExpiringValue[T]) SetValue(value T) {
 	e._value = value
 }
 
+// GetValue returns the value and resets its time, which means that
+// the value's lifetime will be extended.
+// use PeekValue if you don't want to reset the time.
 func (e *ExpiringValue[T]) GetValue() T {
 	e.Reset()
 	return e._value
 }
 
+// PeekValue returns the value without resetting its time.
+func (e *ExpiringValue[T]) PeekValue() T {
+	return e._value
+}
+
 //---------------------------------------------------------
 
 func (e *EndpointError) Error() string {
@@ -1,8 +1,12 @@
 package ssg
 
 import (
+	"container/heap"
+	"context"
+	"io"
+	"iter"
 	"math/rand"
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -48,7 +52,7 @@ func (s *SafeEMap[TKey, TValue]) ToList() GenericList[*TValue] {
 			continue
 		}
 
-		list.Add(v.GetValue())
+		list.Add(s.readValue(v))
 	}
 	s.rUnlock()
 
@@ -66,32 +70,62 @@ func (s *SafeEMap[TKey, TValue]) AddPointerList(keyGetter func(*TValue) TKey, el
 }
 
 func (s *SafeEMap[TKey, TValue]) Add(key TKey, value *TValue) {
-	s.lock()
-	defer s.unlock()
+	s.AddWithTTL(key, value, 0)
+}
+
+// AddWithTTL adds the value to the map with its own lifetime, instead of
+// the default expiration of the map.
+func (s *SafeEMap[TKey, TValue]) AddWithTTL(key TKey, value *TValue, ttl time.Duration) {
+	eValue, event := s.add(key, value, ttl, true)
+	if eValue != nil {
+		s.events.emit(event)
+	}
+}
+
+// add adds the value to the map and returns its expiring-value container,
+// or nil if the map is disabled. the returned event should be emitted by the
+// caller after releasing the lock.
+func (s *SafeEMap[TKey, TValue]) add(
+	key TKey,
+	value *TValue,
+	ttl time.Duration,
+	useLock bool,
+) (*ExpiringValue[*TValue], MapEvent[TKey, TValue]) {
+	if useLock {
+		s.lock()
+		defer s.unlock()
+	}
 
 	if s._disabled {
-		return
+		return nil, MapEvent[TKey, TValue]{}
 	}
 
 	old := s.values[key]
 	if old != nil {
+		event := getSetEvent(key, old.PeekValue(), value, true, s._default)
+
 		// don't allocate new memory if we already have the expiring-value struct in
 		// the map... just set the new value and reset the time
 		old.SetValue(value)
+		old.SetTTL(ttl)
 		old.Reset()
-		return
-	} else {
-		s.values[key] = NewEValue(value)
+		s.schedule(key, old)
+		return old, event
 	}
 
+	eValue := NewEValueWithTTL(value, ttl)
+	s.values[key] = eValue
+	s.schedule(key, eValue)
+
 	s.keys = append(s.keys, key)
 
 	// store the index of the map key
 	index := len(s.keys) - 1
 	s.sliceKeyIndex[key] = index
+	return eValue, getSetEvent(key, nil, value, false, s._default)
 }
 
-func (s *SafeEMap[TKey, TValue]) delete(key TKey, useLock bool) {
+func (s *SafeEMap[TKey, TValue]) delete(key TKey, useLock bool) (*TValue, bool) {
 	if useLock {
 		s.lock()
 	}
@@ -102,7 +136,7 @@ func (s *SafeEMap[TKey, TValue]) delete(key TKey, useLock bool) {
 			s.unlock()
 		}
 		// item does not exist
-		return
+		return nil, false
 	}
 
 	delete(s.sliceKeyIndex, key)
@@ -120,20 +154,36 @@ func (s *SafeEMap[TKey, TValue]) delete(key TKey, useLock bool) {
 		s.sliceKeyIndex[otherKey] = index
 	}
 
+	var value *TValue
+	if eValue := s.values[key]; eValue != nil {
+		value = eValue.PeekValue()
+		if eValue._index >= 0 {
+			heap.Remove(&s.queue, eValue._index)
+		}
+	}
+
 	delete(s.values, key)
 	if useLock {
 		s.unlock()
 	}
+
+	return value, true
 }
 
 func (s *SafeEMap[TKey, TValue]) Delete(key TKey) {
-	s.delete(key, true)
+	value, exists := s.delete(key, true)
+	if exists {
+		s.events.emit(getValueEvent(MapEventDelete, key, value, s._default))
+	}
 }
 
 func (s *SafeEMap[TKey, TValue]) ForEach(fn func(TKey, *TValue) bool) {
 	if fn == nil {
 		return
 	}
+	var events []MapEvent[TKey, TValue]
+	isActive := s.events.isActive()
+
 	s.lock()
 
 	var tmpValue *TValue
@@ -142,15 +192,53 @@ func (s *SafeEMap[TKey, TValue]) ForEach(fn func(TKey, *TValue) bool) {
 		if value == nil {
 			tmpValue = nil
 		} else {
-			tmpValue = value.GetValue()
+			tmpValue = s.readValue(value)
 		}
 
 		if fn(key, tmpValue) {
 			s.delete(key, false)
+			if isActive {
+				events = append(events, getValueEvent(MapEventDelete, key, tmpValue, s._default))
+			}
 		}
 	}
 
 	s.unlock()
+
+	s.events.emit(events...)
+}
+
+// All returns an iterator over the key-value pairs of the map.
+// the iteration is done over a snapshot of the map (reading each value, like ForEach), so the
+// lock isn't held while running the loop body.
+func (s *SafeEMap[TKey, TValue]) All() iter.Seq2[TKey, *TValue] {
+	return snapshotAll(s.snapshot)
+}
+
+// Keys returns an iterator over a snapshot of the keys of the map.
+func (s *SafeEMap[TKey, TValue]) Keys() iter.Seq[TKey] {
+	return snapshotKeys(s.snapshot)
+}
+
+// Values returns an iterator over a snapshot of the values of the map.
+func (s *SafeEMap[TKey, TValue]) Values() iter.Seq[*TValue] {
+	return snapshotValues(s.snapshot)
+}
+
+// snapshot returns a copy of the keys and the values of the map.
+func (s *SafeEMap[TKey, TValue]) snapshot() ([]TKey, []*TValue) {
+	s.rLock()
+	keys := make([]TKey, len(s.keys))
+	copy(keys, s.keys)
+	values := make([]*TValue, len(keys))
+	for i, key := range keys {
+		if eValue := s.values[key]; eValue != nil {
+			values[i] = s.readValue(eValue)
+		}
+	}
+	s.rUnlock()
+
+	return keys, values
 }
 
 func (s *SafeEMap[TKey, TValue]) GetRandom() *TValue {
@@ -161,10 +249,10 @@ func (s *SafeEMap[TKey, TValue]) GetRandom() *TValue {
 	s.rLock()
 	randomIndex := rand.Intn(len(s.keys))
 	key := s.keys[randomIndex]
-	value := s.values[key]
+	value := s.readValue(s.values[key])
 	s.rUnlock()
 
-	return value.GetValue()
+	return value
 }
 
 func (s *SafeEMap[TKey, TValue]) GetRandomValue() TValue {
@@ -175,10 +263,10 @@ func (s *SafeEMap[TKey, TValue]) GetRandomValue() TValue {
 	s.rLock()
 	randomIndex := rand.Intn(len(s.keys))
 	key := s.keys[randomIndex]
-	value := s.values[key]
+	value := s.getRealValue(s.values[key])
 	s.rUnlock()
 
-	return s.getRealValue(value)
+	return value
 }
 
 func (s *SafeEMap[TKey, TValue]) GetRandomKey() (key TKey, ok bool) {
@@ -194,23 +282,142 @@ func (s *SafeEMap[TKey, TValue]) GetRandomKey() (key TKey, ok bool) {
 	return
 }
 
+// GetOrAdd returns the existing value of the key if it's present in the map,
+// otherwise it adds the given value and returns it.
+// loaded will be true if the value was already present in the map.
+func (s *SafeEMap[TKey, TValue]) GetOrAdd(key TKey, value *TValue) (actual *TValue, loaded bool) {
+	return s.GetOrCompute(key, func() *TValue {
+		return value
+	})
+}
+
+// GetOrCompute returns the existing value of the key if it's present in the
+// map, otherwise it adds the value returned by fn and returns it.
+// fn is called at most once, while holding the lock of the map, so it
+// shouldn't use the map itself.
+// loaded will be true if the value was already present in the map.
+func (s *SafeEMap[TKey, TValue]) GetOrCompute(key TKey, fn func() *TValue) (actual *TValue, loaded bool) {
+	s.lock()
+	if eValue := s.values[key]; eValue != nil {
+		actual = s.readValue(eValue)
+		s.unlock()
+		return actual, true
+	}
+
+	if fn == nil {
+		s.unlock()
+		return nil, false
+	}
+
+	actual = fn()
+	eValue, event := s.add(key, actual, 0, false)
+	s.unlock()
+
+	if eValue == nil {
+		return nil, false
+	}
+
+	s.events.emit(event)
+	return actual, false
+}
+
+// Compute calls fn with the current value of the key (if any) and stores
+// the value returned by it; if fn returns false as keep, the key is removed
+// from the map instead. the whole operation is done while holding the lock
+// of the map, so fn shouldn't use the map itself.
+// storing the value resets its lifetime, but keeps its own TTL (if any).
+// it returns the new value of the key and true if the key is present in the
+// map after the operation.
+func (s *SafeEMap[TKey, TValue]) Compute(
+	key TKey,
+	fn func(oldValue *TValue, exists bool) (newValue *TValue, keep bool),
+) (*TValue, bool) {
+	if fn == nil {
+		return nil, false
+	}
+
+	s.lock()
+	var oldValue *TValue
+	var ttl time.Duration
+	old, exists := s.values[key]
+	if old != nil {
+		oldValue = old.PeekValue()
+		ttl = old.GetTTL()
+	}
+
+	if s._disabled {
+		s.unlock()
+		return oldValue, exists
+	}
+
+	value, keep := fn(oldValue, exists)
+	if !keep {
+		if exists {
+			s.delete(key, false)
+		}
+		s.unlock()
+
+		if exists {
+			s.events.emit(getValueEvent(MapEventDelete, key, oldValue, s._default))
+		}
+		return nil, false
+	}
+
+	_, event := s.add(key, value, ttl, false)
+	s.unlock()
+
+	s.events.emit(event)
+	return value, true
+}
+
+// CompareAndSwap replaces the value of the key with newValue, only if the key
+// is present in the map and its current value is oldValue. the values are
+// compared by their pointers.
+// swapping the value resets its lifetime, but keeps its own TTL (if any).
+// it returns true if the value was swapped.
+func (s *SafeEMap[TKey, TValue]) CompareAndSwap(key TKey, oldValue, newValue *TValue) bool {
+	s.lock()
+	current := s.values[key]
+	if current == nil || current.PeekValue() != oldValue || s._disabled {
+		s.unlock()
+		return false
+	}
+
+	_, event := s.add(key, newValue, current.GetTTL(), false)
+	s.unlock()
+
+	s.events.emit(event)
+	return true
+}
+
+// LoadAndDelete removes the key from the map and returns its previous value.
+// loaded will be false if the key wasn't present in the map.
+func (s *SafeEMap[TKey, TValue]) LoadAndDelete(key TKey) (value *TValue, loaded bool) {
+	value, loaded = s.delete(key, true)
+	if loaded {
+		s.events.emit(getValueEvent(MapEventDelete, key, value, s._default))
+	}
+
+	return value, loaded
+}
+
 func (s *SafeEMap[TKey, TValue]) Get(key TKey) *TValue {
 	s.rLock()
+	defer s.rUnlock()
+
 	value := s.values[key]
-	s.rUnlock()
 	if value == nil {
 		return nil
 	}
 
-	return value.GetValue()
+	return s.readValue(value)
 }
 
 func (s *SafeEMap[TKey, TValue]) GetValue(key TKey) TValue {
 	s.rLock()
-	value := s.values[key]
-	s.rUnlock()
+	defer s.rUnlock()
 
-	return s.getRealValue(value)
+	return s.getRealValue(s.values[key])
 }
 
 func (s *SafeEMap[TKey, TValue]) SetDefault(value TValue) {
@@ -234,13 +441,50 @@ func (s *SafeEMap[TKey, TValue]) Set(key TKey, value any) {
 	s.Add(key, correctValue)
 }
 
+// SetWithTTL function sets the key of type TKey in this safe map to the value,
+// with its own lifetime instead of the default expiration of the map.
+// the value should be of type TValue or *TValue, otherwise this function won't
+// do anything at all.
+func (s *SafeEMap[TKey, TValue]) SetWithTTL(key TKey, value any, ttl time.Duration) {
+	correctValue, ok := value.(*TValue)
+	if !ok {
+		anotherValue, ok := value.(TValue)
+		if !ok {
+			return
+		}
+
+		correctValue = &anotherValue
+	}
+
+	s.AddWithTTL(key, correctValue, ttl)
+}
+
+// GetRemainingTime returns the remaining lifetime of the given key.
+// ok will be false if the key doesn't exist in the map.
+func (s *SafeEMap[TKey, TValue]) GetRemainingTime(key TKey) (remaining time.Duration, ok bool) {
+	s.rLock()
+	defer s.rUnlock()
+
+	value := s.values[key]
+	if value == nil {
+		return 0, false
+	}
+
+	return value.GetRemainingTime(s.expiration), true
+}
+
 // Clear will clear the whole map.
 func (s *SafeEMap[TKey, TValue]) Clear() {
 	s.lock()
 	if len(s.values) != 0 {
 		s.values = make(map[TKey]*ExpiringValue[*TValue])
+		s.keys = nil
+		s.sliceKeyIndex = make(map[TKey]int)
+		s.queue = nil
 	}
 	s.unlock()
+
+	s.events.emit(MapEvent[TKey, TValue]{Kind: MapEventClear})
 }
 
 func (s *SafeEMap[TKey, TValue]) Length() int {
@@ -264,7 +508,7 @@ func (s *SafeEMap[TKey, TValue]) ToNormalMap() map[TKey]TValue {
 			continue
 		}
 
-		realValue := v.GetValue()
+		realValue := s.readValue(v)
 		if realValue == nil {
 			m[k] = s._default
 			continue
@@ -286,7 +530,7 @@ func (s *SafeEMap[TKey, TValue]) ToArray() []TValue {
 			continue
 		}
 
-		realValue := v.GetValue()
+		realValue := s.readValue(v)
 		if realValue == nil {
 			array = append(array, s._default)
 			continue
@@ -348,57 +592,258 @@ func (s *SafeEMap[TKey, TValue]) Enable() {
 	s.unlock()
 }
 
+// SaveTo saves the values of the map to the writer, using DefaultSnapshotCodec.
+// the times and TTLs of the values are saved as well.
+func (s *SafeEMap[TKey, TValue]) SaveTo(w io.Writer) error {
+	return s.SaveToWithCodec(w, DefaultSnapshotCodec)
+}
+
+// SaveToWithCodec saves the values of the map to the writer, using the given
+// codec. the times and TTLs of the values are saved as well.
+func (s *SafeEMap[TKey, TValue]) SaveToWithCodec(w io.Writer, codec SnapshotCodec) error {
+	s.rLock()
+	snapshot := &MapSnapshot[TKey, TValue]{
+		Entries: make([]SnapshotEntry[TKey, TValue], 0, len(s.values)),
+	}
+	for key, value := range s.values {
+		snapshot.Entries = append(snapshot.Entries, SnapshotEntry[TKey, TValue]{
+			Key:   key,
+			Value: value.PeekValue(),
+			Time:  atomic.LoadInt64(&value._t),
+			TTL:   value._ttl,
+		})
+	}
+	s.rUnlock()
+
+	return codec.Encode(w, snapshot)
+}
+
+// LoadFrom loads the values saved by SaveTo into the map, using
+// DefaultSnapshotCodec. the values which are already expired are dropped.
+// the existing keys of the map are overwritten, but the other keys are kept.
+func (s *SafeEMap[TKey, TValue]) LoadFrom(r io.Reader) error {
+	return s.LoadFromWithCodec(r, DefaultSnapshotCodec)
+}
+
+// LoadFromWithCodec loads the values saved by SaveTo into the map, using the
+// given codec. the values which are already expired are dropped.
+// the existing keys of the map are overwritten, but the other keys are kept.
+// values saved without a time (e.g. by a SafeMap) are considered as new values.
+func (s *SafeEMap[TKey, TValue]) LoadFromWithCodec(r io.Reader, codec SnapshotCodec) error {
+	snapshot := new(MapSnapshot[TKey, TValue])
+	err := codec.Decode(r, snapshot)
+	if err != nil {
+		return err
+	}
+
+	var events []MapEvent[TKey, TValue]
+	isActive := s.events.isActive()
+
+	s.lock()
+	for _, entry := range snapshot.Entries {
+		eValue, event := s.add(entry.Key, entry.Value, entry.TTL, false)
+		if eValue == nil {
+			continue
+		}
+
+		if entry.Time != 0 {
+			eValue.SetTime(time.Unix(0, entry.Time))
+			deadline, ok := s.getDeadline(eValue)
+			if ok && deadline <= time.Now().UnixNano() {
+				s.delete(entry.Key, false)
+				continue
+			}
+
+			s.schedule(entry.Key, eValue)
+		}
+
+		if isActive {
+			events = append(events, event)
+		}
+	}
+	s.unlock()
+
+	s.events.emit(events...)
+	return nil
+}
+
+// HasValidTimings returns true if the default expiration of the map is valid.
 func (s *SafeEMap[TKey, TValue]) HasValidTimings() bool {
-	return s.expiration > time.Microsecond && s.checkInterval > time.Second
+	s.rLock()
+	defer s.rUnlock()
+
+	return s.expiration > time.Microsecond
 }
 
+// EnableChecking starts the checker loop of the map, which removes the values
+// as soon as they are expired. it doesn't do anything if the loop is already
+// running.
 func (s *SafeEMap[TKey, TValue]) EnableChecking() {
-	if s.checkerMut == nil {
-		s.checkerMut = &sync.Mutex{}
-	}
+	s.EnableCheckingWithContext(context.Background())
+}
 
+// EnableCheckingWithContext starts the checker loop of the map; the loop will
+// be stopped when the given context is done, or when Close is called.
+// it doesn't do anything if the loop is already running.
+func (s *SafeEMap[TKey, TValue]) EnableCheckingWithContext(ctx context.Context) {
 	// this lock here makes sure that only 1 checkLoop is running at a time.
 	s.checkerMut.Lock()
 	defer s.checkerMut.Unlock()
 
-	if s.checkingEnabled {
+	if s.isChecking() {
 		return
 	}
 
-	s.checkingEnabled = true
-	go s.checkLoop()
+	ctx, s.cancelChecking = context.WithCancel(ctx)
+	s.checkingDone = make(chan struct{})
+	go s.checkLoop(ctx, s.checkingDone)
 }
 
+// DisableChecking stops the checker loop of the map and waits for it to exit.
+// it's equivalent to Close.
 func (s *SafeEMap[TKey, TValue]) DisableChecking() {
-	if !s.checkingEnabled {
-		return
+	_ = s.Close()
+}
+
+// Close stops the checker loop of the map and waits for it to exit.
+// the map itself remains usable, but values won't be removed automatically
+// anymore, until the checking is enabled again (DoCheck can still be used).
+// it always returns nil.
+func (s *SafeEMap[TKey, TValue]) Close() error {
+	s.checkerMut.Lock()
+	defer s.checkerMut.Unlock()
+
+	if s.cancelChecking == nil {
+		return nil
 	}
 
-	s.checkingEnabled = false
+	s.cancelChecking()
+	<-s.checkingDone
+	s.cancelChecking = nil
+	s.checkingDone = nil
+	return nil
 }
 
+// IsChecking returns true if the checker loop of the map is running.
 func (s *SafeEMap[TKey, TValue]) IsChecking() bool {
-	return s.checkingEnabled
+	s.checkerMut.Lock()
+	defer s.checkerMut.Unlock()
+
+	return s.isChecking()
+}
+
+// isChecking returns true if the checker loop is running; the caller should
+// hold the checker lock.
+func (s *SafeEMap[TKey, TValue]) isChecking() bool {
+	if s.checkingDone == nil {
+		return false
+	}
+
+	select {
+	case <-s.checkingDone:
+		// the loop has exited because its context is done.
+		return false
+	default:
+		return true
+	}
 }
 
+// SetExpiration sets the default lifetime of the values of the map.
+// values which have their own TTL are not affected by it.
 func (s *SafeEMap[TKey, TValue]) SetExpiration(duration time.Duration) {
+	s.lock()
 	s.expiration = duration
+
+	// the deadlines of all of the values without a TTL of their own have
+	// changed, so rebuild the queue from scratch.
+	s.queue = s.queue[:0]
+	for key, current := range s.values {
+		current._index = -1
+		deadline, ok := s.getDeadline(current)
+		if ok {
+			s.queue = append(s.queue, &expiryItem[TKey, TValue]{
+				key:      key,
+				value:    current,
+				deadline: deadline,
+			})
+			current._index = len(s.queue) - 1
+		}
+	}
+	heap.Init(&s.queue)
+	s.unlock()
+
+	s.wake()
+}
+
+// SetSliding sets whether reading a value from the map should refresh its
+// lifetime or not. sliding is enabled by default.
+func (s *SafeEMap[TKey, TValue]) SetSliding(sliding bool) {
+	s.lock()
+	s.sliding = sliding
+	s.unlock()
 }
 
+// IsSliding returns true if reading a value from the map refreshes its lifetime.
+func (s *SafeEMap[TKey, TValue]) IsSliding() bool {
+	s.rLock()
+	defer s.rUnlock()
+
+	return s.sliding
+}
+
+// SetOnExpired sets the handler which is called when a value is removed from
+// the map because it has expired. it's delivered the same way as the other
+// events of the map (see SetEventOptions).
 func (s *SafeEMap[TKey, TValue]) SetOnExpired(event func(key TKey, value TValue)) {
-	s.onExpired = event
+	s.events.SetOnExpire(event)
+}
+
+// OnAdd registers a handler which is called when a new key is added to the map.
+func (s *SafeEMap[TKey, TValue]) OnAdd(fn func(key TKey, value TValue)) {
+	s.events.OnAdd(fn)
+}
+
+// OnUpdate registers a handler which is called when the value of an existing
+// key is replaced.
+func (s *SafeEMap[TKey, TValue]) OnUpdate(fn func(key TKey, oldValue, value TValue)) {
+	s.events.OnUpdate(fn)
 }
 
-func (s *SafeEMap[TKey, TValue]) SetInterval(duration time.Duration) {
-	s.checkInterval = duration
+// OnDelete registers a handler which is called when a key is removed from
+// the map; expired values are reported to SetOnExpired instead.
+func (s *SafeEMap[TKey, TValue]) OnDelete(fn func(key TKey, value TValue)) {
+	s.events.OnDelete(fn)
 }
 
+// OnClear registers a handler which is called when the map is cleared.
+func (s *SafeEMap[TKey, TValue]) OnClear(fn func()) {
+	s.events.OnClear(fn)
+}
+
+// Watch returns a channel which receives the events of the map (including
+// the expired values) until the context is done.
+func (s *SafeEMap[TKey, TValue]) Watch(ctx context.Context) <-chan MapEvent[TKey, TValue] {
+	return s.events.Watch(ctx)
+}
+
+// SetEventOptions changes how the events of the map are delivered to the
+// handlers and the watchers.
+func (s *SafeEMap[TKey, TValue]) SetEventOptions(options MapEventOptions) {
+	s.events.SetOptions(options)
+}
+
+// SetInterval used to set the interval of the checker loop.
+//
+// Deprecated: the values are now removed as soon as they are expired, so the
+// interval is not used anymore.
+func (s *SafeEMap[TKey, TValue]) SetInterval(duration time.Duration) {}
+
 func (s *SafeEMap[TKey, TValue]) getRealValue(eValue *ExpiringValue[*TValue]) TValue {
 	if eValue == nil {
 		return s._default
 	}
 
-	realValue := eValue.GetValue()
+	realValue := s.readValue(eValue)
 	if realValue == nil {
 		return s._default
 	}
@@ -406,52 +851,166 @@ func (s *SafeEMap[TKey, TValue]) getRealValue(eValue *ExpiringValue[*TValue]) TV
 	return *realValue
 }
 
-// DoCheck iterates over the map and checks for expired variables and removes them.
-// if the `onExpired` member of the map is set, it will call them.
-func (s *SafeEMap[TKey, TValue]) DoCheck() {
-	if !s.IsValid() {
+// readValue returns the value stored in the expiring value, refreshing its
+// lifetime if the map is sliding. the caller should hold the lock.
+func (s *SafeEMap[TKey, TValue]) readValue(eValue *ExpiringValue[*TValue]) *TValue {
+	if s.sliding {
+		return eValue.GetValue()
+	}
+
+	return eValue.PeekValue()
+}
+
+// getDeadline returns the unix-nano time at which the given value expires.
+// ok will be false if the value has no valid lifetime, meaning that it
+// never expires.
+func (s *SafeEMap[TKey, TValue]) getDeadline(eValue *ExpiringValue[*TValue]) (deadline int64, ok bool) {
+	lifetime := eValue._ttl
+	if lifetime <= 0 {
+		lifetime = s.expiration
+	}
+
+	if lifetime <= 0 {
+		return 0, false
+	}
+
+	return atomic.LoadInt64(&eValue._t) + int64(lifetime), true
+}
+
+// schedule puts the value in the expiry queue, or updates its position if it's
+// already there. the caller should hold the lock.
+func (s *SafeEMap[TKey, TValue]) schedule(key TKey, eValue *ExpiringValue[*TValue]) {
+	deadline, ok := s.getDeadline(eValue)
+	if !ok {
+		if eValue._index >= 0 {
+			heap.Remove(&s.queue, eValue._index)
+		}
 		return
 	}
 
-	s.lock()
-	for i, current := range s.values {
-		if current == nil || current.IsExpired(s.expiration) {
-			delete(s.values, i)
-			if s.onExpired != nil {
-				go s.onExpired(i, s.getRealValue(current))
-			}
+	if eValue._index >= 0 {
+		s.queue[eValue._index].deadline = deadline
+		heap.Fix(&s.queue, eValue._index)
+	} else {
+		heap.Push(&s.queue, &expiryItem[TKey, TValue]{
+			key:      key,
+			value:    eValue,
+			deadline: deadline,
+		})
+	}
+
+	if eValue._index == 0 {
+		// this value is the first one to expire now, the checker loop
+		// may be waiting for a later deadline.
+		s.wake()
+	}
+}
+
+// wake wakes the checker loop up (if it's running) so it can recalculate
+// the time it has to wait.
+func (s *SafeEMap[TKey, TValue]) wake() {
+	select {
+	case s.wakeChan <- struct{}{}:
+	default:
+	}
+}
+
+// removeExpired removes at most `limit` due values from the map and appends
+// their expire events to `events`. the caller should hold the lock, and emit
+// the events after releasing it.
+// it returns the count of the items it has processed and the time until the
+// next deadline (or -1 if there are no more scheduled values).
+func (s *SafeEMap[TKey, TValue]) removeExpired(
+	limit int,
+	events *[]MapEvent[TKey, TValue],
+) (int, time.Duration) {
+	now := time.Now().UnixNano()
+	count := 0
+	isActive := s.events.isActive()
+	for len(s.queue) != 0 && count < limit {
+		item := s.queue[0]
+		if item.deadline > now {
+			break
+		}
+
+		count++
+		deadline, ok := s.getDeadline(item.value)
+		if !ok {
+			// the value doesn't expire anymore.
+			heap.Pop(&s.queue)
+			continue
+		}
+
+		if deadline > now {
+			// the value has been refreshed since it was scheduled.
+			item.deadline = deadline
+			heap.Fix(&s.queue, 0)
+			continue
+		}
+
+		value, _ := s.delete(item.key, false)
+		if isActive {
+			*events = append(*events, getValueEvent(MapEventExpire, item.key, value, s._default))
 		}
 	}
-	s.unlock()
+
+	if len(s.queue) == 0 {
+		return count, -1
+	}
+
+	return count, time.Duration(s.queue[0].deadline - now)
 }
 
-func (s *SafeEMap[TKey, TValue]) checkLoop() {
+// expireDue removes all of the due values from the map, releasing the lock
+// after each batch so readers won't be blocked for too long.
+// it returns the time until the next deadline, or -1 if there is none.
+func (s *SafeEMap[TKey, TValue]) expireDue() time.Duration {
+	var events []MapEvent[TKey, TValue]
 	for {
-		time.Sleep(s.checkInterval)
+		s.lock()
+		count, next := s.removeExpired(expiryBatchSize, &events)
+		s.unlock()
 
-		if !s.checkingEnabled {
-			return
+		s.events.emit(events...)
+		events = events[:0]
+
+		if count < expiryBatchSize {
+			return next
 		}
+	}
+}
 
-		if !s.IsValid() {
-			if s.values != nil && s.Length() == 0 {
-				// don't break out if the map is actually valid but it's empty...
-				continue
+// DoCheck removes the expired values from the map.
+// the expire events are emitted for them.
+// only the values which are actually due are touched, so it's cheap to call
+// even on large maps.
+func (s *SafeEMap[TKey, TValue]) DoCheck() {
+	s.expireDue()
+}
+
+func (s *SafeEMap[TKey, TValue]) checkLoop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	for {
+		next := s.expireDue()
+		if next < 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wakeChan:
 			}
 
-			s.checkingEnabled = false
-			return
+			continue
 		}
 
-		s.lock()
-		for i, current := range s.values {
-			if current == nil || current.IsExpired(s.expiration) {
-				delete(s.values, i)
-				if s.onExpired != nil {
-					go s.onExpired(i, s.getRealValue(current))
-				}
-			}
+		timer := time.NewTimer(next)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wakeChan:
+			timer.Stop()
+		case <-timer.C:
 		}
-		s.unlock()
 	}
 }
@@ -0,0 +1,413 @@
+package ssg
+
+import (
+	"iter"
+	"slices"
+)
+
+func (l *SafeList[T]) lock() {
+	l.mut.Lock()
+}
+
+func (l *SafeList[T]) unlock() {
+	l.mut.Unlock()
+}
+
+func (l *SafeList[T]) rLock() {
+	l.mut.RLock()
+}
+
+func (l *SafeList[T]) rUnlock() {
+	l.mut.RUnlock()
+}
+
+// find returns the index of the element in the list; the caller should
+// hold the lock.
+func (l *SafeList[T]) find(element T) int {
+	for i, v := range l._values {
+		if v == element {
+			return i
+		}
+	}
+
+	return LIST_INDEX_NOTFOUND
+}
+
+// removeAt removes the element at the specified index; the caller should
+// hold the lock.
+func (l *SafeList[T]) removeAt(index int) {
+	if index < 0 || index >= len(l._values) {
+		return
+	}
+
+	l._values = append(l._values[:index], l._values[index+1:]...)
+}
+
+func (l *SafeList[T]) Find(element T) int {
+	l.rLock()
+	index := l.find(element)
+	l.rUnlock()
+
+	return index
+}
+
+func (l *SafeList[T]) Count(element T) int {
+	count := 0
+	l.rLock()
+	for _, v := range l._values {
+		if v == element {
+			count++
+		}
+	}
+	l.rUnlock()
+
+	return count
+}
+
+func (l *SafeList[T]) Counts(element ...T) int {
+	count := 0
+	l.rLock()
+	for _, v := range l._values {
+		for _, current := range element {
+			if v == current {
+				count++
+			}
+		}
+	}
+	l.rUnlock()
+
+	return count
+}
+
+func (l *SafeList[T]) Contains(element T) bool {
+	return l.Find(element) != LIST_INDEX_NOTFOUND
+}
+
+func (l *SafeList[T]) ContainsAll(elements ...T) bool {
+	l.rLock()
+	defer l.rUnlock()
+
+	for _, current := range elements {
+		if l.find(current) == LIST_INDEX_NOTFOUND {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (l *SafeList[T]) ContainsOne(elements ...T) bool {
+	l.rLock()
+	defer l.rUnlock()
+
+	for _, current := range elements {
+		if l.find(current) != LIST_INDEX_NOTFOUND {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *SafeList[T]) Change(index int, element T) {
+	l.lock()
+	if index >= 0 && index < len(l._values) {
+		l._values[index] = element
+	}
+	l.unlock()
+}
+
+func (l *SafeList[T]) Exists(element T) bool {
+	return l.Find(element) != LIST_INDEX_NOTFOUND
+}
+
+func (l *SafeList[T]) Append(elements ...T) {
+	l.lock()
+	l._values = append(l._values, elements...)
+	l.unlock()
+}
+
+func (l *SafeList[T]) Add(elements ...T) {
+	l.Append(elements...)
+}
+
+// AppendIfAbsent appends each of the given elements to the list only if
+// it's not already present in it. the whole operation is done atomically.
+// it returns the count of the elements which were actually appended.
+func (l *SafeList[T]) AppendIfAbsent(elements ...T) int {
+	count := 0
+	l.lock()
+	for _, current := range elements {
+		if l.find(current) == LIST_INDEX_NOTFOUND {
+			l._values = append(l._values, current)
+			count++
+		}
+	}
+	l.unlock()
+
+	return count
+}
+
+// RemoveAt removes the element at the specified index.
+// it won't do anything if the index is out of range.
+func (l *SafeList[T]) RemoveAt(index int) {
+	l.lock()
+	l.removeAt(index)
+	l.unlock()
+}
+
+func (l *SafeList[T]) RemoveOnce(element T) {
+	l.lock()
+	l.removeAt(l.find(element))
+	l.unlock()
+}
+
+// RemoveAll removes all of the occurrences of the given elements from
+// the list.
+func (l *SafeList[T]) RemoveAll(element ...T) {
+	l.lock()
+	newVal := make([]T, 0, len(l._values))
+	for _, v := range l._values {
+		found := false
+		for _, current := range element {
+			if v == current {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			newVal = append(newVal, v)
+		}
+	}
+
+	l._values = newVal
+	l.unlock()
+}
+
+func (l *SafeList[T]) Remove(element T) {
+	l.RemoveOnce(element)
+}
+
+// RemoveWhere removes all of the elements for which the given function
+// returns true. the whole operation is done atomically, so please don't
+// call any other method of this list inside of fn.
+// it returns the count of the removed elements.
+func (l *SafeList[T]) RemoveWhere(fn func(element T) bool) int {
+	if fn == nil {
+		return 0
+	}
+
+	l.lock()
+	newVal := make([]T, 0, len(l._values))
+	for _, v := range l._values {
+		if !fn(v) {
+			newVal = append(newVal, v)
+		}
+	}
+
+	count := len(l._values) - len(newVal)
+	l._values = newVal
+	l.unlock()
+
+	return count
+}
+
+// ForEach calls fn for each of the elements of the list.
+// the iteration is done on a snapshot of the list, so the lock is not being
+// held while fn is called and it's safe to modify the list inside of fn;
+// those changes won't be seen by the current iteration.
+// returning true from fn will stop the iteration.
+func (l *SafeList[T]) ForEach(fn func(index int, element T) bool) {
+	if fn == nil {
+		return
+	}
+
+	for i, current := range l.AsArray() {
+		if fn(i, current) {
+			return
+		}
+	}
+}
+
+// All returns an iterator over the indexes and the elements of the list.
+// the iteration is done over a snapshot of the list (see AsArray), which is
+// taken when the iteration starts.
+func (l *SafeList[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		for i, current := range l.AsArray() {
+			if !yield(i, current) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over a snapshot of the elements of the list.
+func (l *SafeList[T]) Values() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, current := range l.AsArray() {
+			if !yield(current) {
+				return
+			}
+		}
+	}
+}
+
+// Filter returns a new thread safe list containing the elements of this list
+// for which fn returns true. fn is called over a snapshot of the list, so it
+// may use the list itself.
+func (l *SafeList[T]) Filter(fn func(element T) bool) GenericList[T] {
+	return newSafeListFromArray(filterArray(l.AsArray(), fn))
+}
+
+// Where is equivalent to Filter in any way.
+func (l *SafeList[T]) Where(fn func(element T) bool) GenericList[T] {
+	return l.Filter(fn)
+}
+
+// FindFunc returns the index of the first element for which fn returns true,
+// or LIST_INDEX_NOTFOUND if there is no such element. fn is called over a
+// snapshot of the list, so the index may be changed by other goroutines.
+func (l *SafeList[T]) FindFunc(fn func(element T) bool) int {
+	if fn == nil {
+		return LIST_INDEX_NOTFOUND
+	}
+
+	return slices.IndexFunc(l.AsArray(), fn)
+}
+
+// IndexOf is equivalent to Find in any way.
+func (l *SafeList[T]) IndexOf(element T) int {
+	return l.Find(element)
+}
+
+// SortFunc returns a new thread safe list containing the elements of this
+// list, sorted using cmp (see slices.SortStableFunc).
+func (l *SafeList[T]) SortFunc(cmp func(a, b T) int) GenericList[T] {
+	values := l.AsArray()
+	if cmp != nil {
+		slices.SortStableFunc(values, cmp)
+	}
+
+	return newSafeListFromArray(values)
+}
+
+// Distinct returns a new thread safe list containing the first occurrence of
+// each of the elements of this list.
+func (l *SafeList[T]) Distinct() GenericList[T] {
+	l.rLock()
+	values := distinctArray(l._values)
+	l.rUnlock()
+
+	return newSafeListFromArray(values)
+}
+
+// Reverse returns a new thread safe list containing the elements of this
+// list in the reverse order.
+func (l *SafeList[T]) Reverse() GenericList[T] {
+	values := l.AsArray()
+	slices.Reverse(values)
+	return newSafeListFromArray(values)
+}
+
+// Chunk splits the list into new thread safe lists of at most `size`
+// elements. it returns nil if size is not positive.
+func (l *SafeList[T]) Chunk(size int) []GenericList[T] {
+	l.rLock()
+	chunks := chunkArray(l._values, size)
+	l.rUnlock()
+
+	var lists []GenericList[T]
+	for _, current := range chunks {
+		lists = append(lists, newSafeListFromArray(current))
+	}
+
+	return lists
+}
+
+// Insert inserts the elements at the specified index, moving the elements
+// after it forward; it doesn't do anything if the index is out of range.
+// unlike the other query methods, it changes the list itself, and returns
+// it for chaining.
+func (l *SafeList[T]) Insert(index int, elements ...T) GenericList[T] {
+	l.lock()
+	if index >= 0 && index <= len(l._values) {
+		l._values = slices.Insert(l._values, index, elements...)
+	}
+	l.unlock()
+
+	return l
+}
+
+// Slice returns a new thread safe list containing the elements in the range
+// [from, to) of this list; the range is clamped to the length of the list.
+func (l *SafeList[T]) Slice(from, to int) GenericList[T] {
+	l.rLock()
+	values := sliceArray(l._values, from, to)
+	l.rUnlock()
+
+	return newSafeListFromArray(values)
+}
+
+// AsArray returns a copy of the value of this list as an array.
+// please do notice that if you make changes to the underlying values of
+// that array, change won't be applied to the list.
+func (l *SafeList[T]) AsArray() []T {
+	l.rLock()
+	var arr = make([]T, len(l._values))
+	copy(arr, l._values)
+	l.rUnlock()
+
+	return arr
+}
+
+// ToArray is equivalent to AsArray method in any way.
+// it returns a copy of the value of this list as an array.
+// please do notice that if you make changes to the underlying values of
+// that array, change won't be applied to the list.
+func (l *SafeList[T]) ToArray() []T {
+	return l.AsArray()
+}
+
+// Clear method clears the whole list.
+func (l *SafeList[T]) Clear() {
+	l.lock()
+	l._values = nil
+	l.unlock()
+}
+
+// Get returns the element at the specified index.
+// unlike ListW, it won't panic if the index is out of range (as another
+// goroutine may have removed some elements in the meantime), it will return
+// the zero value of T instead.
+func (l *SafeList[T]) Get(index int) T {
+	var value T
+	l.rLock()
+	if index >= 0 && index < len(l._values) {
+		value = l._values[index]
+	}
+	l.rUnlock()
+
+	return value
+}
+
+func (l *SafeList[T]) IsThreadSafe() bool {
+	return true
+}
+
+func (l *SafeList[T]) IsEmpty() bool {
+	return l.Length() == 0
+}
+
+func (l *SafeList[T]) Length() int {
+	l.rLock()
+	length := len(l._values)
+	l.rUnlock()
+
+	return length
+}
+
+func (l *SafeList[T]) IsValid() bool {
+	return l.Length() > 0
+}
@@ -1,5 +1,11 @@
 package ssg
 
+import (
+	"context"
+	"io"
+	"iter"
+)
+
 func (s *SafeMap[TKey, TValue]) lock() {
 	s.mut.Lock()
 }
@@ -25,12 +31,16 @@ func (s *SafeMap[TKey, TValue]) Exists(key TKey) bool {
 
 func (s *SafeMap[TKey, TValue]) Add(key TKey, value *TValue) {
 	s.lock()
-	defer s.unlock()
-
 	if s._disabled {
+		s.unlock()
 		return
 	}
+
+	oldValue, exists := s.values[key]
 	s.values[key] = value
+	s.unlock()
+
+	s.events.emit(getSetEvent(key, oldValue, value, exists, s._default))
 }
 
 func (s *SafeMap[TKey, TValue]) ForEach(fn func(TKey, *TValue) bool) {
@@ -38,14 +48,53 @@ func (s *SafeMap[TKey, TValue]) ForEach(fn func(TKey, *TValue) bool) {
 		return
 	}
 
+	var events []MapEvent[TKey, TValue]
+	isActive := s.events.isActive()
+
 	s.lock()
 	for key, value := range s.values {
 		if fn(key, value) {
 			s.delete(key, false)
+			if isActive {
+				events = append(events, getValueEvent(MapEventDelete, key, value, s._default))
+			}
 		}
 	}
 
 	s.unlock()
+
+	s.events.emit(events...)
+}
+
+// All returns an iterator over the key-value pairs of the map.
+// the iteration is done over a snapshot of the map, so the
+// lock isn't held while running the loop body.
+func (s *SafeMap[TKey, TValue]) All() iter.Seq2[TKey, *TValue] {
+	return snapshotAll(s.snapshot)
+}
+
+// Keys returns an iterator over a snapshot of the keys of the map.
+func (s *SafeMap[TKey, TValue]) Keys() iter.Seq[TKey] {
+	return snapshotKeys(s.snapshot)
+}
+
+// Values returns an iterator over a snapshot of the values of the map.
+func (s *SafeMap[TKey, TValue]) Values() iter.Seq[*TValue] {
+	return snapshotValues(s.snapshot)
+}
+
+// snapshot returns a copy of the keys and the values of the map.
+func (s *SafeMap[TKey, TValue]) snapshot() ([]TKey, []*TValue) {
+	s.rLock()
+	keys := make([]TKey, 0, len(s.values))
+	values := make([]*TValue, 0, len(s.values))
+	for key, value := range s.values {
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	s.rUnlock()
+
+	return keys, values
 }
 
 func (s *SafeMap[TKey, TValue]) ToArray() []TValue {
@@ -116,17 +165,123 @@ func (s *SafeMap[TKey, TValue]) AddPointerList(keyGetter func(*TValue) TKey, ele
 	}
 }
 
-func (s *SafeMap[TKey, TValue]) delete(key TKey, useLock bool) {
+func (s *SafeMap[TKey, TValue]) delete(key TKey, useLock bool) (*TValue, bool) {
 	if useLock {
 		s.lock()
 		defer s.unlock()
 	}
 
+	value, exists := s.values[key]
 	delete(s.values, key)
+	return value, exists
 }
 
 func (s *SafeMap[TKey, TValue]) Delete(key TKey) {
-	s.delete(key, true)
+	value, exists := s.delete(key, true)
+	if exists {
+		s.events.emit(getValueEvent(MapEventDelete, key, value, s._default))
+	}
+}
+
+// GetOrAdd returns the existing value of the key if it's present in the map,
+// otherwise it adds the given value and returns it.
+// loaded will be true if the value was already present in the map.
+func (s *SafeMap[TKey, TValue]) GetOrAdd(key TKey, value *TValue) (actual *TValue, loaded bool) {
+	return s.GetOrCompute(key, func() *TValue {
+		return value
+	})
+}
+
+// GetOrCompute returns the existing value of the key if it's present in the
+// map, otherwise it adds the value returned by fn and returns it.
+// fn is called at most once, while holding the lock of the map, so it
+// shouldn't use the map itself.
+// loaded will be true if the value was already present in the map.
+func (s *SafeMap[TKey, TValue]) GetOrCompute(key TKey, fn func() *TValue) (actual *TValue, loaded bool) {
+	s.lock()
+	actual, loaded = s.values[key]
+	if loaded || s._disabled || fn == nil {
+		s.unlock()
+		return actual, loaded
+	}
+
+	actual = fn()
+	s.values[key] = actual
+	s.unlock()
+
+	s.events.emit(getSetEvent(key, nil, actual, false, s._default))
+	return actual, false
+}
+
+// Compute calls fn with the current value of the key (if any) and stores
+// the value returned by it; if fn returns false as keep, the key is removed
+// from the map instead. the whole operation is done while holding the lock
+// of the map, so fn shouldn't use the map itself.
+// it returns the new value of the key and true if the key is present in the
+// map after the operation.
+func (s *SafeMap[TKey, TValue]) Compute(
+	key TKey,
+	fn func(oldValue *TValue, exists bool) (newValue *TValue, keep bool),
+) (*TValue, bool) {
+	if fn == nil {
+		return nil, false
+	}
+
+	s.lock()
+	oldValue, exists := s.values[key]
+	if s._disabled {
+		s.unlock()
+		return oldValue, exists
+	}
+
+	value, keep := fn(oldValue, exists)
+	if !keep {
+		if exists {
+			s.delete(key, false)
+		}
+		s.unlock()
+
+		if exists {
+			s.events.emit(getValueEvent(MapEventDelete, key, oldValue, s._default))
+		}
+		return nil, false
+	}
+
+	s.values[key] = value
+	s.unlock()
+
+	s.events.emit(getSetEvent(key, oldValue, value, exists, s._default))
+	return value, true
+}
+
+// CompareAndSwap replaces the value of the key with newValue, only if the key
+// is present in the map and its current value is oldValue. the values are
+// compared by their pointers.
+// it returns true if the value was swapped.
+func (s *SafeMap[TKey, TValue]) CompareAndSwap(key TKey, oldValue, newValue *TValue) bool {
+	s.lock()
+	current, exists := s.values[key]
+	if !exists || current != oldValue || s._disabled {
+		s.unlock()
+		return false
+	}
+
+	s.values[key] = newValue
+	s.unlock()
+
+	s.events.emit(getSetEvent(key, oldValue, newValue, true, s._default))
+	return true
+}
+
+// LoadAndDelete removes the key from the map and returns its previous value.
+// loaded will be false if the key wasn't present in the map.
+func (s *SafeMap[TKey, TValue]) LoadAndDelete(key TKey) (value *TValue, loaded bool) {
+	value, loaded = s.delete(key, true)
+	if loaded {
+		s.events.emit(getValueEvent(MapEventDelete, key, value, s._default))
+	}
+
+	return value, loaded
 }
 
 func (s *SafeMap[TKey, TValue]) Get(key TKey) *TValue {
@@ -176,6 +331,8 @@ func (s *SafeMap[TKey, TValue]) Clear() {
 		s.values = make(map[TKey]*TValue)
 	}
 	s.unlock()
+
+	s.events.emit(MapEvent[TKey, TValue]{Kind: MapEventClear})
 }
 
 func (s *SafeMap[TKey, TValue]) Length() int {
@@ -238,3 +395,99 @@ func (s *SafeMap[TKey, TValue]) Enable() {
 	s._disabled = false
 	s.unlock()
 }
+
+// SaveTo saves the values of the map to the writer, using DefaultSnapshotCodec.
+func (s *SafeMap[TKey, TValue]) SaveTo(w io.Writer) error {
+	return s.SaveToWithCodec(w, DefaultSnapshotCodec)
+}
+
+// SaveToWithCodec saves the values of the map to the writer, using the given
+// codec.
+func (s *SafeMap[TKey, TValue]) SaveToWithCodec(w io.Writer, codec SnapshotCodec) error {
+	s.rLock()
+	snapshot := &MapSnapshot[TKey, TValue]{
+		Entries: make([]SnapshotEntry[TKey, TValue], 0, len(s.values)),
+	}
+	for key, value := range s.values {
+		snapshot.Entries = append(snapshot.Entries, SnapshotEntry[TKey, TValue]{
+			Key:   key,
+			Value: value,
+		})
+	}
+	s.rUnlock()
+
+	return codec.Encode(w, snapshot)
+}
+
+// LoadFrom loads the values saved by SaveTo into the map, using
+// DefaultSnapshotCodec. the existing keys of the map are overwritten, but
+// the other keys are kept.
+func (s *SafeMap[TKey, TValue]) LoadFrom(r io.Reader) error {
+	return s.LoadFromWithCodec(r, DefaultSnapshotCodec)
+}
+
+// LoadFromWithCodec loads the values saved by SaveTo into the map, using the
+// given codec. the existing keys of the map are overwritten, but the other
+// keys are kept.
+func (s *SafeMap[TKey, TValue]) LoadFromWithCodec(r io.Reader, codec SnapshotCodec) error {
+	snapshot := new(MapSnapshot[TKey, TValue])
+	err := codec.Decode(r, snapshot)
+	if err != nil {
+		return err
+	}
+
+	var events []MapEvent[TKey, TValue]
+	isActive := s.events.isActive()
+
+	s.lock()
+	if s._disabled {
+		s.unlock()
+		return nil
+	}
+
+	for _, entry := range snapshot.Entries {
+		oldValue, exists := s.values[entry.Key]
+		s.values[entry.Key] = entry.Value
+		if isActive {
+			events = append(events, getSetEvent(entry.Key, oldValue, entry.Value, exists, s._default))
+		}
+	}
+	s.unlock()
+
+	s.events.emit(events...)
+	return nil
+}
+
+// OnAdd registers a handler which is called when a new key is added to the map.
+func (s *SafeMap[TKey, TValue]) OnAdd(fn func(key TKey, value TValue)) {
+	s.events.OnAdd(fn)
+}
+
+// OnUpdate registers a handler which is called when the value of an existing
+// key is replaced.
+func (s *SafeMap[TKey, TValue]) OnUpdate(fn func(key TKey, oldValue, value TValue)) {
+	s.events.OnUpdate(fn)
+}
+
+// OnDelete registers a handler which is called when a key is removed from
+// the map.
+func (s *SafeMap[TKey, TValue]) OnDelete(fn func(key TKey, value TValue)) {
+	s.events.OnDelete(fn)
+}
+
+// OnClear registers a handler which is called when the map is cleared.
+func (s *SafeMap[TKey, TValue]) OnClear(fn func()) {
+	s.events.OnClear(fn)
+}
+
+// Watch returns a channel which receives the events of the map until the
+// context is done.
+func (s *SafeMap[TKey, TValue]) Watch(ctx context.Context) <-chan MapEvent[TKey, TValue] {
+	return s.events.Watch(ctx)
+}
+
+// SetEventOptions changes how the events of the map are delivered to the
+// handlers and the watchers.
+func (s *SafeMap[TKey, TValue]) SetEventOptions(options MapEventOptions) {
+	s.events.SetOptions(options)
+}
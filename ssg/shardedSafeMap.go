@@ -0,0 +1,246 @@
+package ssg
+
+import "iter"
+
+// getShard returns the shard which the given key belongs to.
+func (s *ShardedSafeMap[TKey, TValue]) getShard(key TKey) *SafeMap[TKey, TValue] {
+	return s.shards[s.hasher(key)%uint64(len(s.shards))]
+}
+
+// ShardCount returns the count of the shards of this map.
+func (s *ShardedSafeMap[TKey, TValue]) ShardCount() int {
+	return len(s.shards)
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) Exists(key TKey) bool {
+	return s.getShard(key).Exists(key)
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) Add(key TKey, value *TValue) {
+	s.getShard(key).Add(key, value)
+}
+
+// ForEach calls fn for each of the key-value pairs of the map; returning true
+// from fn will delete the key from the map.
+// only the lock of the shard which is being iterated is held while calling fn,
+// so changes made to other shards in the meantime may or may not be seen.
+func (s *ShardedSafeMap[TKey, TValue]) ForEach(fn func(TKey, *TValue) bool) {
+	if fn == nil {
+		return
+	}
+
+	for _, shard := range s.shards {
+		shard.ForEach(fn)
+	}
+}
+
+// All returns an iterator over the key-value pairs of the map.
+// each shard is iterated over a snapshot of its own, which is taken when the
+// iteration reaches that shard.
+func (s *ShardedSafeMap[TKey, TValue]) All() iter.Seq2[TKey, *TValue] {
+	return func(yield func(TKey, *TValue) bool) {
+		for _, shard := range s.shards {
+			for key, value := range shard.All() {
+				if !yield(key, value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Keys returns an iterator over the keys of the map, shard by shard.
+func (s *ShardedSafeMap[TKey, TValue]) Keys() iter.Seq[TKey] {
+	return func(yield func(TKey) bool) {
+		for key := range s.All() {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over the values of the map, shard by shard.
+func (s *ShardedSafeMap[TKey, TValue]) Values() iter.Seq[*TValue] {
+	return func(yield func(*TValue) bool) {
+		for _, value := range s.All() {
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) ToArray() []TValue {
+	var array []TValue
+	for _, shard := range s.shards {
+		array = append(array, shard.ToArray()...)
+	}
+
+	return array
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) ToPointerArray() []*TValue {
+	var array []*TValue
+	for _, shard := range s.shards {
+		array = append(array, shard.ToPointerArray()...)
+	}
+
+	return array
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) ToList() GenericList[*TValue] {
+	return GetListFromArray(s.ToPointerArray())
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) AddList(keyGetter func(*TValue) TKey, elements ...TValue) {
+	if len(elements) == 0 || keyGetter == nil {
+		return
+	}
+
+	for _, current := range elements {
+		s.Add(keyGetter(&current), &current)
+	}
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) AddPointerList(keyGetter func(*TValue) TKey, elements ...*TValue) {
+	if len(elements) == 0 || keyGetter == nil {
+		return
+	}
+
+	for _, current := range elements {
+		s.Add(keyGetter(current), current)
+	}
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) Delete(key TKey) {
+	s.getShard(key).Delete(key)
+}
+
+// GetOrAdd works like SafeMap.GetOrAdd, only locking the shard of the key.
+func (s *ShardedSafeMap[TKey, TValue]) GetOrAdd(key TKey, value *TValue) (actual *TValue, loaded bool) {
+	return s.getShard(key).GetOrAdd(key, value)
+}
+
+// GetOrCompute works like SafeMap.GetOrCompute, only locking the shard of the key.
+func (s *ShardedSafeMap[TKey, TValue]) GetOrCompute(key TKey, fn func() *TValue) (actual *TValue, loaded bool) {
+	return s.getShard(key).GetOrCompute(key, fn)
+}
+
+// Compute works like SafeMap.Compute, only locking the shard of the key.
+func (s *ShardedSafeMap[TKey, TValue]) Compute(
+	key TKey,
+	fn func(oldValue *TValue, exists bool) (newValue *TValue, keep bool),
+) (*TValue, bool) {
+	return s.getShard(key).Compute(key, fn)
+}
+
+// CompareAndSwap works like SafeMap.CompareAndSwap, only locking the shard of the key.
+func (s *ShardedSafeMap[TKey, TValue]) CompareAndSwap(key TKey, oldValue, newValue *TValue) bool {
+	return s.getShard(key).CompareAndSwap(key, oldValue, newValue)
+}
+
+// LoadAndDelete works like SafeMap.LoadAndDelete, only locking the shard of the key.
+func (s *ShardedSafeMap[TKey, TValue]) LoadAndDelete(key TKey) (value *TValue, loaded bool) {
+	return s.getShard(key).LoadAndDelete(key)
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) Get(key TKey) *TValue {
+	return s.getShard(key).Get(key)
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) GetValue(key TKey) TValue {
+	value := s.getShard(key).Get(key)
+	if value == nil {
+		return s._default
+	}
+
+	return *value
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) SetDefault(value TValue) {
+	s._default = value
+	for _, shard := range s.shards {
+		shard.SetDefault(value)
+	}
+}
+
+// Set function sets the key of type TKey in this safe map to the value.
+// the value should be of type TValue or *TValue, otherwise this function won't
+// do anything at all.
+func (s *ShardedSafeMap[TKey, TValue]) Set(key TKey, value any) {
+	s.getShard(key).Set(key, value)
+}
+
+// Clear will clear the whole map.
+func (s *ShardedSafeMap[TKey, TValue]) Clear() {
+	for _, shard := range s.shards {
+		shard.Clear()
+	}
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) Length() int {
+	l := 0
+	for _, shard := range s.shards {
+		l += shard.Length()
+	}
+
+	return l
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) IsEmpty() bool {
+	for _, shard := range s.shards {
+		if !shard.IsEmpty() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) ToNormalMap() map[TKey]TValue {
+	m := make(map[TKey]TValue)
+	for _, shard := range s.shards {
+		for k, v := range shard.ToNormalMap() {
+			m[k] = v
+		}
+	}
+
+	return m
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) IsThreadSafe() bool {
+	return true
+}
+
+func (s *ShardedSafeMap[TKey, TValue]) IsValid() bool {
+	return !s.IsEmpty()
+}
+
+// IsDisabled returns true if this map is disabled.
+// Disabled maps won't be able to add new values, but will still be able to
+// delete/read values.
+func (s *ShardedSafeMap[TKey, TValue]) IsDisabled() bool {
+	for _, shard := range s.shards {
+		if !shard.IsDisabled() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Disable will disable this map, meaning that it won't be able to add new
+// values, but will still be able to delete/read values.
+func (s *ShardedSafeMap[TKey, TValue]) Disable() {
+	for _, shard := range s.shards {
+		shard.Disable()
+	}
+}
+
+// Enable will enable this map, meaning that it will be able to add new values.
+func (s *ShardedSafeMap[TKey, TValue]) Enable() {
+	for _, shard := range s.shards {
+		shard.Enable()
+	}
+}
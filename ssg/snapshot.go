@@ -0,0 +1,107 @@
+package ssg
+
+import (
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/AnimeKaizoku/ssg/ssg/internal"
+)
+
+func (JSONSnapshotCodec) Encode(w io.Writer, value any) error {
+	return json.NewEncoder(w).Encode(value)
+}
+
+func (JSONSnapshotCodec) Decode(r io.Reader, value any) error {
+	return json.NewDecoder(r).Decode(value)
+}
+
+func (GobSnapshotCodec) Encode(w io.Writer, value any) error {
+	return gob.NewEncoder(w).Encode(value)
+}
+
+func (GobSnapshotCodec) Decode(r io.Reader, value any) error {
+	return gob.NewDecoder(r).Decode(value)
+}
+
+//---------------------------------------------------------
+
+// SaveSnapshotFile saves the values of the map to the given file path.
+// the snapshot is written to a temporary file in the same directory first,
+// which is then renamed to the path, so the file is never left half-written.
+// if codec is nil, DefaultSnapshotCodec will be used.
+func SaveSnapshotFile(s Snapshotter, path string, codec SnapshotCodec) error {
+	if codec == nil {
+		codec = DefaultSnapshotCodec
+	}
+
+	return internal.WriteFileAtomic(path, 0600, func(w io.Writer) error {
+		return s.SaveToWithCodec(w, codec)
+	})
+}
+
+// LoadSnapshotFile loads the values saved by SaveSnapshotFile into the map.
+// if codec is nil, DefaultSnapshotCodec will be used.
+func LoadSnapshotFile(l SnapshotLoader, path string, codec SnapshotCodec) error {
+	if codec == nil {
+		codec = DefaultSnapshotCodec
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return l.LoadFromWithCodec(file, codec)
+}
+
+// StartAutoSnapshot saves the values of the map to the given file path every
+// `interval`, in another goroutine, until the context is done. a last
+// snapshot is saved when the context is done, and the returned channel is
+// closed after that.
+// errors are passed to onError (if it's not nil); they don't stop the loop.
+// if codec is nil, DefaultSnapshotCodec will be used.
+// it returns ErrInvalidSnapshotInterval if the interval isn't positive.
+func StartAutoSnapshot(
+	ctx context.Context,
+	s Snapshotter,
+	path string,
+	interval time.Duration,
+	codec SnapshotCodec,
+	onError func(err error),
+) (<-chan struct{}, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidSnapshotInterval
+	}
+
+	done := make(chan struct{})
+	save := func() {
+		err := SaveSnapshotFile(s, path, codec)
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				save()
+				return
+			case <-ticker.C:
+				save()
+			}
+		}
+	}()
+
+	return done, nil
+}
@@ -1,5 +1,98 @@
 package strongParser
 
+import "time"
+
+// DefaultWatchInterval is the default interval of checking the watched
+// config files for changes.
+const DefaultWatchInterval = 2 * time.Second
+
 const (
 	defaultSectionName = "DEFAULT"
+
+	// envSectionName is the section name which refers to the environment
+	// variables in the extended interpolation, such as "${ENV:HOME}".
+	envSectionName = "ENV"
+
+	// maxInterpolationDepth is the maximum count of the nested references
+	// which are interpolated in a value.
+	maxInterpolationDepth = 10
+
+	// includeDirective is the option which includes the other files when
+	// it's placed before the first section, such as "include = conf/*.ini".
+	includeDirective = "include"
+
+	// maxIncludeDepth is the maximum count of the nested included files.
+	maxIncludeDepth = 16
+
+	// dropInPattern is the pattern of the files of the drop-in directory.
+	dropInPattern = "*.ini"
+
+	// maxStructDepth is the maximum count of the nested structs which are
+	// parsed, so a struct which contains a pointer to itself won't cause
+	// an endless recursion.
+	maxStructDepth = 32
+
+	// repeatedSectionSuffix is the placeholder of the index of the sections
+	// of the elements of a slice of structs, in the schema of the config.
+	repeatedSectionSuffix = "<n>"
+
+	// continuationIndent is the indentation of the continuation lines of
+	// the multi-line values.
+	continuationIndent = "    "
+)
+
+const (
+	blankToken iniTokenKind = iota
+	commentToken
+	sectionToken
+	optionToken
+	invalidToken
+)
+
+const (
+	// FileSecretPrefix is the prefix of the secret references which are
+	// read from a file, such as "file:///run/secrets/token".
+	FileSecretPrefix = "file://"
+
+	// EnvSecretPrefix is the prefix of the secret references which are read
+	// from an environment variable, such as "env://BOT_TOKEN".
+	EnvSecretPrefix = "env://"
+
+	// EncryptedSecretPrefix is the prefix of the values which are encrypted
+	// by EncryptSecret.
+	EncryptedSecretPrefix = "enc:"
+
+	// SecretMask replaces the values of the secret fields when they are
+	// dumped by DumpConfig; fmt only masks the values of the Secret type.
+	SecretMask = "******"
+)
+
+const (
+	// SourceFile means the value comes from the parsed config.
+	SourceFile ValueSource = "file"
+
+	// SourceEnv means the value comes from an environment variable.
+	SourceEnv ValueSource = "env"
+
+	// SourceDefault means the value comes from the `default` tag.
+	SourceDefault ValueSource = "default"
+
+	// SourceFlag means the value comes from a command-line flag.
+	SourceFlag ValueSource = "flag"
+)
+
+const (
+	// NoInterpolation keeps the values as they are.
+	NoInterpolation InterpolationMode = iota
+
+	// BasicInterpolation replaces "%(name)s" by the value of the option
+	// from the same section (or the DEFAULT section); "%%" is replaced
+	// by "%".
+	BasicInterpolation
+
+	// ExtendedInterpolation replaces "${name}" by the value of the option
+	// from the same section (or the DEFAULT section), "${section:name}" by
+	// the value of the option from the given section and "${ENV:NAME}" by
+	// the value of the environment variable; "$$" is replaced by "$".
+	ExtendedInterpolation
 )
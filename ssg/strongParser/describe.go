@@ -0,0 +1,308 @@
+package strongParser
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Describe returns the schema of the config struct (or pointer to struct):
+// the options which ParseConfig looks for, with their types, defaults and
+// the environment variables which are tried for them.
+func Describe(value any) (*ConfigSchema, error) {
+	return DescribeWithOptions(value, nil)
+}
+
+// DescribeWithOptions is the same as Describe, for the config which is
+// parsed with the given options.
+func DescribeWithOptions(value any, opt *ConfigParserOptions) (*ConfigSchema, error) {
+	myType := reflect.TypeOf(value)
+	if myType != nil && myType.Kind() == reflect.Ptr {
+		myType = myType.Elem()
+	}
+
+	if myType == nil || myType.Kind() != reflect.Struct {
+		return nil, &InvalidParseError{reflect.TypeOf(value)}
+	}
+
+	p := NewConfigParser()
+	p.options = opt
+	if p.options == nil {
+		p.options = getDefaultOptions()
+	}
+
+	schema := &ConfigSchema{}
+	err := describeStructType(myType, "", "", p, schema, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+// describeStructType adds the fields of the struct type to the schema; path
+// is the path of the struct field, such as "Database".
+func describeStructType(
+	myType reflect.Type,
+	path, section string,
+	p *ConfigParser,
+	schema *ConfigSchema,
+	depth int,
+) error {
+	if depth > maxStructDepth {
+		return fmt.Errorf("strongParser: more than %d nested structs in %s", maxStructDepth, myType)
+	}
+
+	for i := 0; i < myType.NumField(); i++ {
+		fByName := myType.Field(i)
+		if !fByName.IsExported() && !fByName.Anonymous {
+			continue
+		}
+
+		fieldPath := fByName.Name
+		if path != "" {
+			fieldPath = path + "." + fByName.Name
+		}
+
+		fType := fByName.Type
+		switch {
+		case getConvertibleType(fType) != nil:
+			if fByName.IsExported() {
+				schema.Fields = append(schema.Fields, newFieldSchema(fieldPath, fByName, section, p))
+			}
+		case getStructType(fType) != nil:
+			nestedPath, nestedSection := fieldPath, section
+			if fByName.Anonymous {
+				nestedPath = path
+			} else {
+				nestedSection = getNestedSectionName(fByName)
+			}
+
+			err := describeStructType(getStructType(fType), nestedPath, nestedSection, p, schema, depth+1)
+			if err != nil {
+				return err
+			}
+		case !fByName.IsExported():
+			continue
+		case fType.Kind() == reflect.Map && fType.Key().Kind() == reflect.String:
+			schema.Fields = append(schema.Fields, &FieldSchema{
+				Field:       fieldPath,
+				Section:     getNestedSectionName(fByName),
+				Key:         "*",
+				Type:        fType.String(),
+				Required:    BoolMapping[strings.ToLower(fByName.Tag.Get("required"))],
+				Secret:      isSecretField(fByName),
+				Description: fByName.Tag.Get("desc"),
+			})
+		case fType.Kind() == reflect.Slice && getStructType(fType.Elem()) != nil:
+			// each of the sections named "prefix.something" is an element.
+			prefix := getNestedSectionName(fByName) + "."
+			err := describeStructType(
+				getStructType(fType.Elem()),
+				fieldPath+"[]",
+				prefix+repeatedSectionSuffix,
+				p, schema, depth+1,
+			)
+			if err != nil {
+				return err
+			}
+		case isDescribedKind(fType.Kind()) || isDescribedKind(GetPointerKind(fType)):
+			schema.Fields = append(schema.Fields, newFieldSchema(fieldPath, fByName, section, p))
+		}
+	}
+
+	return nil
+}
+
+// newFieldSchema returns the schema of the option of the field; section is
+// the section of its struct (if any).
+func newFieldSchema(path string, fByName reflect.StructField, section string, p *ConfigParser) *FieldSchema {
+	section, key := getFieldLocation(fByName, section, p)
+	if section == "" {
+		section = DefaultMainSection
+	}
+
+	fieldType := fByName.Type.String()
+	if tagType := strings.ToLower(fByName.Tag.Get("type")); tagType != "" {
+		fieldType = tagType
+	}
+
+	var envTries []string
+	if kind := fByName.Type.Kind(); (kind == reflect.Slice || kind == reflect.Array) &&
+		getConvertibleType(fByName.Type) == nil {
+		// the arrays always try the environment variables.
+		envTries = p.getArrayEnvTries(section, key, fByName.Tag.Get("env"))
+	} else {
+		envTries = getEnvTries(fByName, section, key, p)
+	}
+
+	if p.options.EnvOverrides {
+		envTries = append([]string{p.getEnvKey(section, key)}, envTries...)
+	}
+
+	fieldSchema := &FieldSchema{
+		Field:       path,
+		Section:     section,
+		Key:         key,
+		Type:        fieldType,
+		Default:     fByName.Tag.Get("default"),
+		Required:    BoolMapping[strings.ToLower(fByName.Tag.Get("required"))],
+		Secret:      isSecretField(fByName),
+		Description: fByName.Tag.Get("desc"),
+	}
+
+	for _, envTry := range envTries {
+		if envTry != "" && !slices.Contains(fieldSchema.Env, envTry) {
+			fieldSchema.Env = append(fieldSchema.Env, envTry)
+		}
+	}
+
+	if fieldSchema.Secret {
+		fieldSchema.Default = maskSecret(fieldSchema.Default)
+	}
+
+	return fieldSchema
+}
+
+// isDescribedKind returns true if the fields of the kind (or the pointers
+// to it) are parsed from the options.
+func isDescribedKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128,
+		reflect.Slice, reflect.Array:
+		return true
+	}
+
+	return false
+}
+
+//---------------------------------------------------------
+
+// WriteSampleINI writes a sample config in the INI format to w, with the
+// default values of the options and comments which describe them.
+func (s *ConfigSchema) WriteSampleINI(w io.Writer) (int64, error) {
+	writer := &countingWriter{w: w}
+	for i, section := range s.getSections() {
+		if i != 0 {
+			writer.WriteString("\n")
+		}
+
+		sampleSection := strings.ReplaceAll(section, repeatedSectionSuffix, "1")
+		writer.WriteString("[" + sampleSection + "]\n")
+		if sampleSection != section {
+			writer.WriteString("; repeated as [" + strings.ReplaceAll(section, repeatedSectionSuffix, "1") +
+				"], [" + strings.ReplaceAll(section, repeatedSectionSuffix, "2") + "], ...\n")
+		}
+
+		for _, field := range s.Fields {
+			if field.Section != section {
+				continue
+			}
+
+			if field.Description != "" {
+				for _, line := range strings.Split(field.Description, "\n") {
+					writer.WriteString("; " + line + "\n")
+				}
+			}
+
+			writer.WriteString("; " + field.getSummary() + "\n")
+			if field.Key == "*" {
+				writer.WriteString("; any key = value\n")
+				continue
+			}
+
+			writer.WriteString(field.Key + " = " + formatOptionValue(field.Default, false) + "\n")
+		}
+	}
+
+	return writer.n, writer.err
+}
+
+// WriteMarkdown writes a Markdown table of the options to w.
+func (s *ConfigSchema) WriteMarkdown(w io.Writer) (int64, error) {
+	writer := &countingWriter{w: w}
+	writer.WriteString("| Section | Key | Type | Default | Environment | Required | Description |\n")
+	writer.WriteString("|---|---|---|---|---|---|---|\n")
+	for _, field := range s.Fields {
+		env := make([]string, 0, len(field.Env))
+		for _, current := range field.Env {
+			env = append(env, "`"+current+"`")
+		}
+
+		required := "no"
+		if field.Required {
+			required = "yes"
+		}
+
+		defaultValue := ""
+		if field.Default != "" {
+			defaultValue = "`" + field.Default + "`"
+		}
+
+		cells := []string{
+			field.Section,
+			"`" + field.Key + "`",
+			"`" + field.Type + "`",
+			defaultValue,
+			strings.Join(env, ", "),
+			required,
+			field.Description,
+		}
+
+		for i := range cells {
+			cells[i] = escapeMarkdownCell(cells[i])
+		}
+
+		writer.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+
+	return writer.n, writer.err
+}
+
+// getSections returns the sections of the fields, in the order of the
+// fields.
+func (s *ConfigSchema) getSections() []string {
+	var sections []string
+	for _, field := range s.Fields {
+		if !slices.Contains(sections, field.Section) {
+			sections = append(sections, field.Section)
+		}
+	}
+
+	return sections
+}
+
+//---------------------------------------------------------
+
+// getSummary returns the type of the option, whether it's required and the
+// environment variables which are tried for it, such as
+// "int64, required, env: MAIN_BOT_ID, bot_id, BOT_ID".
+func (f *FieldSchema) getSummary() string {
+	parts := []string{f.Type}
+	if f.Required {
+		parts = append(parts, "required")
+	}
+
+	if f.Secret {
+		parts = append(parts, "secret")
+	}
+
+	if len(f.Env) != 0 {
+		parts = append(parts, "env: "+strings.Join(f.Env, ", "))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+//---------------------------------------------------------
+
+// escapeMarkdownCell escapes the pipes and the newlines of the text of a
+// Markdown table cell.
+func escapeMarkdownCell(value string) string {
+	return markdownCellReplacer.Replace(value)
+}
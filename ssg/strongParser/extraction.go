@@ -1,22 +1,71 @@
 package strongParser
 
 import (
-	"os"
+	"encoding"
+	"fmt"
+	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
-// extractFieldValue
+// extractFieldValue returns the value of the field, converted by the converter;
+// the value is read from the config, then from the environment variables (or
+// the other way around, if the PreferEnv option is true) and at last from the
+// `default` tag. a source is used if it has the value, even if it's empty or
+// zero; the values which can't be converted fall back to the next source,
+// and they are added to the field errors of the parser in the strict mode.
+// found is false if none of the sources has a valid value.
 func extractFieldValue[T comparable](
 	parser *ConfigParser,
 	myType reflect.Type,
 	currentIndex int, section string,
-	converter fieldValueConverter[T]) T {
+	converter fieldValueConverter[T]) (T, bool) {
+	return extractStructFieldValue(parser, myType, myType.Field(currentIndex), section, converter)
+}
+
+// extractStructFieldValue is the same as extractFieldValue, for the given
+// field of myType.
+func extractStructFieldValue[T comparable](
+	parser *ConfigParser,
+	myType reflect.Type,
+	fByName reflect.StructField,
+	section string,
+	converter fieldValueConverter[T]) (T, bool) {
+
+	section, key := getFieldLocation(fByName, section, parser)
+	fType := strings.ToLower(fByName.Tag.Get("type"))
+	for _, source := range parser.getValueSources() {
+		value, found, err := parser.getFieldRawValue(fByName, source, section, key)
+		if err != nil {
+			parser.addFieldError(myType, fByName, section, key, source, value, err)
+			continue
+		} else if !found {
+			continue
+		}
+
+		resultValue, err := converter(fType, value)
+		if err == nil {
+			return resultValue, true
+		}
 
-	var realDefault T
-	var resultValue T
-	fByName := myType.Field(currentIndex)
+		parser.addConversionError(myType, fByName, section, key, source, value, err)
+	}
+
+	defaultValue, found := fByName.Tag.Lookup("default")
+	resultValue, err := converter(fType, defaultValue)
+	if err != nil {
+		parser.addConversionError(myType, fByName, section, key, SourceDefault, defaultValue, err)
+		return resultValue, false
+	}
+
+	return resultValue, found
+}
+
+// getFieldLocation returns the section and the key of the option of the
+// field; section is the section of the struct (if any).
+func getFieldLocation(fByName reflect.StructField, section string, parser *ConfigParser) (string, string) {
 	if section == "" {
 		section = fByName.Tag.Get("section")
 	}
@@ -31,44 +80,68 @@ func extractFieldValue[T comparable](
 		key = toSnakeCase(fByName.Name)
 	}
 
-	fType := strings.ToLower(fByName.Tag.Get("type"))
-	theValue, err := parser.Get(section, key)
-	if err == nil {
-		// first try: from config file.
-		resultValue, err = converter(fType, theValue)
-		if err == nil && resultValue != realDefault {
-			return resultValue
-		}
-	}
+	return section, key
+}
 
-	envTag := fByName.Tag.Get("env")
-	var envTries []string
-	if envTag == "" && parser.options.ReadEnv {
-		// if there is no env tag and we are told to allow
-		// reading values from env, try to read it from env.
-		if section != "" {
-			envTries = append(envTries, strings.ToUpper(section)+"_"+strings.ToUpper(key))
-		}
-		envTries = append(envTries, key)
-		envTries = append(envTries, strings.ToUpper(key))
-	} else {
+// getEnvTries returns the names of the environment variables which the value
+// of the field is read from, in order.
+func getEnvTries(fByName reflect.StructField, section, key string, parser *ConfigParser) []string {
+	if envTag := fByName.Tag.Get("env"); envTag != "" {
 		// if we are given an env tag, just use that, instead of trying a few times
 		// to find the correct variable in env...
-		envTries = append(envTries, envTag)
+		return []string{envTag}
+	} else if parser.options.EnvPrefix != "" {
+		// the prefixed variables are only tried by their exact names.
+		return []string{parser.getEnvKey(section, key)}
+	} else if !parser.options.ReadEnv && parser.envValues == nil {
+		return nil
 	}
 
-	for _, envTry := range envTries {
-		envValue := os.Getenv(envTry)
-		if envValue != "" {
-			resultValue, err = converter(fType, envValue)
-			if err == nil && resultValue != realDefault {
-				return resultValue
-			}
+	// if there is no env tag and we are told to allow
+	// reading values from env, try to read it from env.
+	var envTries []string
+	if section != "" {
+		envTries = append(envTries, strings.ToUpper(section)+"_"+strings.ToUpper(key))
+	}
+	envTries = append(envTries, key)
+	envTries = append(envTries, strings.ToUpper(key))
+
+	return envTries
+}
+
+// getFieldSource returns where the value of the field comes from; it's empty
+// if the field has no value in any of the sources.
+func getFieldSource(fByName reflect.StructField, section, key string, parser *ConfigParser) ValueSource {
+	for _, source := range parser.getValueSources() {
+		value, found, _ := parser.getFieldRawValue(fByName, source, section, key)
+		if found && value != "" {
+			return source
+		}
+	}
+
+	if fByName.Tag.Get("default") != "" {
+		return SourceDefault
+	}
+
+	return ""
+}
+
+// checkArrayValue returns an error if one of the elements of the array value
+// can't be converted to the element type.
+func checkArrayValue(value string, elemType reflect.Type, isRune bool) error {
+	fType := ""
+	if isRune {
+		fType = "rune"
+	}
+
+	for _, current := range splitArrayValue(value) {
+		err := setValueFromString(reflect.New(elemType).Elem(), fType, current)
+		if err != nil {
+			return err
 		}
 	}
 
-	resultValue, _ = converter(fType, fByName.Tag.Get("default"))
-	return resultValue
+	return nil
 }
 
 func toSnakeCase(s string) string {
@@ -110,7 +183,12 @@ func extractUInt64(_, strValue string) (uint64, error) {
 }
 
 func extractBool(fType, strValue string) (bool, error) {
-	return BoolMapping[strings.ToLower(strValue)], nil
+	value, present := BoolMapping[strings.ToLower(strValue)]
+	if !present && strValue != "" {
+		return false, fmt.Errorf("not a boolean: '%s'", strValue)
+	}
+
+	return value, nil
 }
 
 func extractFloat64(fType, strValue string) (float64, error) {
@@ -120,3 +198,168 @@ func extractFloat64(fType, strValue string) (float64, error) {
 func extractComplex128(fType, strValue string) (complex128, error) {
 	return strconv.ParseComplex(strValue, 128)
 }
+
+func extractDuration(_, strValue string) (time.Duration, error) {
+	return time.ParseDuration(strValue)
+}
+
+// extractTime parses the RFC3339 timestamps; the "2006-01-02 15:04:05" and
+// "2006-01-02" formats are accepted too.
+func extractTime(_, strValue string) (time.Time, error) {
+	var err error
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		var value time.Time
+		value, err = time.Parse(layout, strValue)
+		if err == nil {
+			return value, nil
+		}
+	}
+
+	return time.Time{}, err
+}
+
+func extractURL(_, strValue string) (url.URL, error) {
+	value, err := url.Parse(strValue)
+	if err != nil {
+		return url.URL{}, err
+	}
+
+	return *value, nil
+}
+
+// setValueFromString converts the string value to the kind of the field and
+// sets it; fType is the `type` tag of the field (if any).
+func setValueFromString(field rValue, fType, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		intValue, err := extractInt64(fType, value)
+		if err != nil {
+			return err
+		} else if field.OverflowInt(intValue) {
+			return fmt.Errorf("value %s overflows %s", value, field.Type())
+		}
+
+		field.SetInt(intValue)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		uintValue, err := extractUInt64(fType, value)
+		if err != nil {
+			return err
+		} else if field.OverflowUint(uintValue) {
+			return fmt.Errorf("value %s overflows %s", value, field.Type())
+		}
+
+		field.SetUint(uintValue)
+	case reflect.Float32, reflect.Float64:
+		floatValue, err := extractFloat64(fType, value)
+		if err != nil {
+			return err
+		}
+
+		field.SetFloat(floatValue)
+	case reflect.Complex64, reflect.Complex128:
+		complexValue, err := extractComplex128(fType, value)
+		if err != nil {
+			return err
+		}
+
+		field.SetComplex(complexValue)
+	case reflect.Bool:
+		boolValue, present := BoolMapping[strings.ToLower(value)]
+		if !present {
+			return fmt.Errorf("not a boolean: '%s'", value)
+		}
+
+		field.SetBool(boolValue)
+	default:
+		return fmt.Errorf("unsupported kind: %s", field.Kind())
+	}
+
+	return nil
+}
+
+// formatFieldValue returns the string representation of the field value, the
+// way it would be parsed back; fType is the `type` tag of the field (if any).
+// ok will be false if the field is a nil pointer, a nil slice or of an
+// unsupported kind.
+func formatFieldValue(field rValue, fType string) (value string, ok bool) {
+	if field.Kind() != reflect.Ptr && field.Kind() != reflect.Interface &&
+		getValueConverter(field.Type()) != nil {
+		if value, ok := formatConvertibleValue(field); ok {
+			return value, true
+		}
+	}
+
+	switch field.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if field.IsNil() {
+			return "", false
+		}
+
+		return formatFieldValue(field.Elem(), fType)
+	case reflect.String:
+		return field.String(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if fType == "rune" {
+			if field.Int() == 0 {
+				return "", true
+			}
+
+			return string(rune(field.Int())), true
+		}
+
+		return strconv.FormatInt(field.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(field.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(field.Float(), 'g', -1, field.Type().Bits()), true
+	case reflect.Complex64, reflect.Complex128:
+		return strconv.FormatComplex(field.Complex(), 'g', -1, field.Type().Bits()), true
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool()), true
+	case reflect.Slice, reflect.Array:
+		if field.Kind() == reflect.Slice && field.IsNil() {
+			return "", false
+		}
+
+		elemType := strings.TrimPrefix(fType, "[]")
+		values := make([]string, 0, field.Len())
+		for i := 0; i < field.Len(); i++ {
+			current, ok := formatFieldValue(field.Index(i), elemType)
+			if ok {
+				values = append(values, current)
+			}
+		}
+
+		return strings.Join(values, ", "), true
+	}
+
+	return "", false
+}
+
+// formatConvertibleValue returns the string representation of a value which
+// has a converter, using its MarshalText or String method; ok will be false
+// if it has neither of them.
+func formatConvertibleValue(field rValue) (string, bool) {
+	if !field.CanAddr() {
+		// the methods with pointer receivers need an addressable value.
+		copied := reflect.New(field.Type()).Elem()
+		copied.Set(field)
+		field = copied
+	}
+
+	switch value := field.Addr().Interface().(type) {
+	case encoding.TextMarshaler:
+		text, err := value.MarshalText()
+		if err != nil {
+			return "", false
+		}
+
+		return string(text), true
+	case fmt.Stringer:
+		return value.String(), true
+	}
+
+	return "", false
+}
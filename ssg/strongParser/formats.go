@@ -0,0 +1,925 @@
+package strongParser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RegisterFormat registers the config format of the files with the given
+// extension (such as ".json"); ParseConfig and the other functions which
+// take a filename detect the format of the file by its extension, using
+// the INI format for the unknown extensions.
+func RegisterFormat(extension string, format ConfigFormat) {
+	configFormatsMutex.Lock()
+	configFormats[strings.ToLower(extension)] = format
+	configFormatsMutex.Unlock()
+}
+
+// GetFormat returns the config format of the file, detected by its
+// extension; it's INIFormat if the extension is unknown.
+func GetFormat(filename string) ConfigFormat {
+	configFormatsMutex.RLock()
+	format := configFormats[strings.ToLower(filepath.Ext(filename))]
+	configFormatsMutex.RUnlock()
+
+	if format == nil {
+		return INIFormat
+	}
+
+	return format
+}
+
+// ParseWithFormat parses the data of the given config format into a
+// ConfigParser value, using the given options; like ParseStringWithOptions,
+// it only loads the included and drop-in files if the BaseDir option is set.
+func ParseWithFormat(data []byte, format ConfigFormat, opt *ConfigParserOptions) (*ConfigParser, error) {
+	return parseLayers(data, format, opt)
+}
+
+// ParseConfigWithFormat parses the data of the given config format into the
+// struct value, using the given options.
+func ParseConfigWithFormat(value any, data []byte, format ConfigFormat, opt *ConfigParserOptions) error {
+	p, err := parseLayers(data, format, opt)
+	if err != nil {
+		return err
+	}
+
+	return parseFinalConfig(value, "", p)
+}
+
+func decodeINI(data []byte, opt *ConfigParserOptions) (*ConfigParser, error) {
+	return parseBytes(data, opt)
+}
+
+func decodeJSON(data []byte, opt *ConfigParserOptions) (*ConfigParser, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var tree map[string]any
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, fmt.Errorf("json: %w", err)
+	}
+
+	return newParserFromTree(tree, opt), nil
+}
+
+func decodeTOML(data []byte, opt *ConfigParserOptions) (*ConfigParser, error) {
+	decoder := &tomlDecoder{data: string(data), line: 1}
+	tree, err := decoder.decode()
+	if err != nil {
+		return nil, err
+	}
+
+	return newParserFromTree(tree, opt), nil
+}
+
+func decodeYAML(data []byte, opt *ConfigParserOptions) (*ConfigParser, error) {
+	tree, err := decodeYAMLTree(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return newParserFromTree(tree, opt), nil
+}
+
+// decodeEnv decodes the "KEY=value" lines of a .env file; the variables are
+// used like the environment variables (which take precedence over them).
+func decodeEnv(data []byte, opt *ConfigParserOptions) (*ConfigParser, error) {
+	p := NewConfigParser()
+	p.options = opt
+	p.envValues = make(map[string]string)
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("env: line %d: invalid variable: %s", i+1, line)
+		}
+
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, `"`):
+			unquoted, ok := unquoteValue(value, []string{"#"})
+			if !ok {
+				return nil, fmt.Errorf("env: line %d: invalid quoted value: %s", i+1, value)
+			}
+
+			value = unquoted
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end == -1 {
+				return nil, fmt.Errorf("env: line %d: invalid quoted value: %s", i+1, value)
+			}
+
+			value = value[1 : end+1]
+		default:
+			value = strings.TrimSpace(stripInlineComment(value, []string{"#"}))
+		}
+
+		p.envValues[key] = value
+	}
+
+	return p, nil
+}
+
+// newParserFromTree returns a ConfigParser with the values of the decoded
+// tree. the maps of the tree are the sections ("parent.child" for the nested
+// ones), the slices of maps are the sections named "key.1", "key.2" and so
+// on, the slices of the other values are joined by commas, and the values
+// at the top of the tree are the options of the main section.
+func newParserFromTree(tree map[string]any, opt *ConfigParserOptions) *ConfigParser {
+	p := NewConfigParser()
+	p.options = opt
+	p.addTreeValues("", tree)
+	return p
+}
+
+// addTreeValues adds the values of the tree to the section; section is empty
+// for the top of the tree.
+func (p *ConfigParser) addTreeValues(section string, tree map[string]any) {
+	keys := make([]string, 0, len(tree))
+	for key := range tree {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		nestedSection := key
+		if section != "" {
+			nestedSection = section + "." + key
+		}
+
+		switch value := tree[key].(type) {
+		case nil:
+			continue
+		case map[string]any:
+			p.getOrAddSection(nestedSection)
+			p.addTreeValues(nestedSection, value)
+		case []any:
+			if isSliceOfTrees(value) {
+				for i, elem := range value {
+					elemSection := nestedSection + "." + strconv.Itoa(i+1)
+					p.getOrAddSection(elemSection)
+					p.addTreeValues(elemSection, elem.(map[string]any))
+				}
+
+				continue
+			}
+
+			values := make([]string, 0, len(value))
+			for _, elem := range value {
+				values = append(values, formatTreeValue(elem))
+			}
+
+			p.addTreeOption(section, key, strings.Join(values, ", "))
+		default:
+			p.addTreeOption(section, key, formatTreeValue(value))
+		}
+	}
+}
+
+// addTreeOption adds the option to the section, or to the main section if
+// section is empty.
+func (p *ConfigParser) addTreeOption(section, key, value string) {
+	if section == "" {
+		section = DefaultMainSection
+		if p.options != nil && p.options.MainSectionName != "" {
+			section = p.options.MainSectionName
+		}
+	}
+
+	s := p.getOrAddSection(section)
+	s.add(key, value, "")
+	s.origins[s.safeKey(key)] = &ValueOrigin{Source: SourceFile}
+}
+
+// isSliceOfTrees returns true if all of the elements of the non-empty slice
+// are maps.
+func isSliceOfTrees(value []any) bool {
+	for _, elem := range value {
+		if _, ok := elem.(map[string]any); !ok {
+			return false
+		}
+	}
+
+	return len(value) != 0
+}
+
+// formatTreeValue returns the string representation of a scalar value of a
+// decoded tree.
+func formatTreeValue(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case []any, map[string]any:
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+
+	return fmt.Sprint(value)
+}
+
+//---------------------------------------------------------
+
+// decode decodes the TOML document into a tree; the tables are maps and the
+// arrays of tables are slices of maps. the integers, floats and dates are
+// kept as strings.
+func (d *tomlDecoder) decode() (map[string]any, error) {
+	root := make(map[string]any)
+	current := root
+
+	for {
+		d.skipSpaces(true)
+		if d.pos >= len(d.data) {
+			return root, nil
+		}
+
+		var err error
+		switch {
+		case strings.HasPrefix(d.data[d.pos:], "[["):
+			d.pos += 2
+			current, err = d.decodeTableHeader(root, true)
+		case d.data[d.pos] == '[':
+			d.pos++
+			current, err = d.decodeTableHeader(root, false)
+		default:
+			err = d.decodeKeyValue(current)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err = d.expectLineEnd(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decodeTableHeader decodes the "[table]" (or "[[table]]") header and
+// returns the table of its body.
+func (d *tomlDecoder) decodeTableHeader(root map[string]any, isArray bool) (map[string]any, error) {
+	path, err := d.decodeKey()
+	if err != nil {
+		return nil, err
+	}
+
+	closing := "]"
+	if isArray {
+		closing = "]]"
+	}
+
+	d.skipSpaces(false)
+	if !strings.HasPrefix(d.data[d.pos:], closing) {
+		return nil, d.errorf("expected %s", closing)
+	}
+	d.pos += len(closing)
+
+	if !isArray {
+		return d.getTable(root, path)
+	}
+
+	parent, err := d.getTable(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	last := path[len(path)-1]
+	tables, _ := parent[last].([]any)
+	if parent[last] != nil && tables == nil {
+		return nil, d.errorf("'%s' is not an array of tables", last)
+	}
+
+	table := make(map[string]any)
+	parent[last] = append(tables, table)
+	return table, nil
+}
+
+// getTable returns the table of the path, creating it if it doesn't exist;
+// the last table of an array of tables is used.
+func (d *tomlDecoder) getTable(root map[string]any, path []string) (map[string]any, error) {
+	current := root
+	for _, key := range path {
+		switch value := current[key].(type) {
+		case nil:
+			table := make(map[string]any)
+			current[key] = table
+			current = table
+		case map[string]any:
+			current = value
+		case []any:
+			if !isSliceOfTrees(value) {
+				return nil, d.errorf("'%s' is not a table", key)
+			}
+
+			current = value[len(value)-1].(map[string]any)
+		default:
+			return nil, d.errorf("'%s' is not a table", key)
+		}
+	}
+
+	return current, nil
+}
+
+// decodeKeyValue decodes the "key = value" pair into the table.
+func (d *tomlDecoder) decodeKeyValue(table map[string]any) error {
+	path, err := d.decodeKey()
+	if err != nil {
+		return err
+	}
+
+	d.skipSpaces(false)
+	if d.pos >= len(d.data) || d.data[d.pos] != '=' {
+		return d.errorf("expected '='")
+	}
+	d.pos++
+
+	value, err := d.decodeValue()
+	if err != nil {
+		return err
+	}
+
+	table, err = d.getTable(table, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+
+	table[path[len(path)-1]] = value
+	return nil
+}
+
+// decodeKey decodes a (possibly dotted) key.
+func (d *tomlDecoder) decodeKey() ([]string, error) {
+	var path []string
+	for {
+		d.skipSpaces(false)
+		if d.pos >= len(d.data) {
+			return nil, d.errorf("expected a key")
+		}
+
+		var part string
+		var err error
+		switch d.data[d.pos] {
+		case '"', '\'':
+			part, err = d.decodeString()
+		default:
+			start := d.pos
+			for d.pos < len(d.data) && isTOMLBareKeyChar(d.data[d.pos]) {
+				d.pos++
+			}
+
+			part = d.data[start:d.pos]
+			if part == "" {
+				err = d.errorf("expected a key")
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		path = append(path, part)
+		d.skipSpaces(false)
+		if d.pos >= len(d.data) || d.data[d.pos] != '.' {
+			return path, nil
+		}
+		d.pos++
+	}
+}
+
+// decodeValue decodes a string, an array, an inline table or a scalar.
+func (d *tomlDecoder) decodeValue() (any, error) {
+	d.skipSpaces(false)
+	if d.pos >= len(d.data) {
+		return nil, d.errorf("expected a value")
+	}
+
+	switch d.data[d.pos] {
+	case '"', '\'':
+		return d.decodeString()
+	case '[':
+		d.pos++
+		var values []any
+		for {
+			d.skipSpaces(true)
+			if d.pos < len(d.data) && d.data[d.pos] == ']' {
+				d.pos++
+				return values, nil
+			}
+
+			value, err := d.decodeValue()
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, value)
+			d.skipSpaces(true)
+			if d.pos < len(d.data) && d.data[d.pos] == ',' {
+				d.pos++
+			} else if d.pos >= len(d.data) || d.data[d.pos] != ']' {
+				return nil, d.errorf("expected ',' or ']'")
+			}
+		}
+	case '{':
+		d.pos++
+		table := make(map[string]any)
+		for {
+			d.skipSpaces(false)
+			if d.pos < len(d.data) && d.data[d.pos] == '}' {
+				d.pos++
+				return table, nil
+			}
+
+			if err := d.decodeKeyValue(table); err != nil {
+				return nil, err
+			}
+
+			d.skipSpaces(false)
+			if d.pos < len(d.data) && d.data[d.pos] == ',' {
+				d.pos++
+			} else if d.pos >= len(d.data) || d.data[d.pos] != '}' {
+				return nil, d.errorf("expected ',' or '}'")
+			}
+		}
+	}
+
+	start := d.pos
+	for d.pos < len(d.data) && !strings.ContainsRune(",]}#\r\n", rune(d.data[d.pos])) {
+		d.pos++
+	}
+
+	value := strings.TrimSpace(d.data[start:d.pos])
+	switch value {
+	case "":
+		return nil, d.errorf("expected a value")
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+
+	if strings.ContainsAny(value, "0123456789") && !strings.ContainsAny(value, ":-T ") {
+		// the underscores of the numbers, such as 1_000.
+		value = strings.ReplaceAll(value, "_", "")
+	}
+
+	return value, nil
+}
+
+// decodeString decodes the basic ("...") and literal ('...') strings, and
+// their multi-line forms between the triple quotes.
+func (d *tomlDecoder) decodeString() (string, error) {
+	quote := d.data[d.pos : d.pos+1]
+	isLiteral := quote == "'"
+	if strings.HasPrefix(d.data[d.pos:], quote+quote+quote) {
+		d.pos += 3
+		// a newline right after the opening quotes is trimmed.
+		if strings.HasPrefix(d.data[d.pos:], "\r\n") {
+			d.pos += 2
+		} else if strings.HasPrefix(d.data[d.pos:], "\n") {
+			d.pos++
+		}
+
+		end := strings.Index(d.data[d.pos:], quote+quote+quote)
+		if end == -1 {
+			return "", d.errorf("unterminated multi-line string")
+		}
+
+		value := d.data[d.pos : d.pos+end]
+		d.line += strings.Count(value, "\n")
+		d.pos += end + 3
+		if isLiteral {
+			return value, nil
+		}
+
+		return d.unescape(value)
+	}
+
+	d.pos++
+	start := d.pos
+	for d.pos < len(d.data) && d.data[d.pos] != quote[0] {
+		if d.data[d.pos] == '\n' {
+			return "", d.errorf("unterminated string")
+		} else if d.data[d.pos] == '\\' && !isLiteral {
+			d.pos++
+		}
+
+		d.pos++
+	}
+
+	if d.pos >= len(d.data) {
+		return "", d.errorf("unterminated string")
+	}
+
+	value := d.data[start:d.pos]
+	d.pos++
+	if isLiteral {
+		return value, nil
+	}
+
+	return d.unescape(value)
+}
+
+// unescape replaces the escape sequences of a basic string.
+func (d *tomlDecoder) unescape(value string) (string, error) {
+	if !strings.Contains(value, `\`) {
+		return value, nil
+	}
+
+	// a backslash at the end of a line trims the following whitespaces.
+	lines := strings.Split(value, "\n")
+	for i := 0; i < len(lines)-1; i++ {
+		if strings.HasSuffix(strings.TrimRight(lines[i], " \t\r"), `\`) {
+			lines[i] = strings.TrimSuffix(strings.TrimRight(lines[i], " \t\r"), `\`)
+			lines[i+1] = strings.TrimLeft(lines[i+1], " \t")
+			lines[i] += "\x00"
+		}
+	}
+	value = strings.ReplaceAll(strings.Join(lines, "\n"), "\x00\n", "")
+
+	unquoted, err := strconv.Unquote(`"` + strings.ReplaceAll(value, "\n", `\n`) + `"`)
+	if err != nil {
+		return "", d.errorf("invalid escape sequence in string")
+	}
+
+	return unquoted, nil
+}
+
+// skipSpaces skips the whitespaces and the comments; the newlines are only
+// skipped if newLines is true.
+func (d *tomlDecoder) skipSpaces(newLines bool) {
+	for d.pos < len(d.data) {
+		switch d.data[d.pos] {
+		case ' ', '\t', '\r':
+			d.pos++
+		case '\n':
+			if !newLines {
+				return
+			}
+
+			d.line++
+			d.pos++
+		case '#':
+			for d.pos < len(d.data) && d.data[d.pos] != '\n' {
+				d.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// expectLineEnd returns an error if there is anything other than a comment
+// before the end of the line.
+func (d *tomlDecoder) expectLineEnd() error {
+	d.skipSpaces(false)
+	if d.pos < len(d.data) && d.data[d.pos] != '\n' {
+		return d.errorf("expected the end of the line")
+	}
+
+	return nil
+}
+
+func (d *tomlDecoder) errorf(format string, args ...any) error {
+	return fmt.Errorf("toml: line %d: %s", d.line, fmt.Sprintf(format, args...))
+}
+
+func isTOMLBareKeyChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9') || c == '_' || c == '-'
+}
+
+//---------------------------------------------------------
+
+// decodeYAMLTree decodes a subset of YAML into a tree: the block mappings
+// and sequences, the plain and quoted scalars, the literal (|) and folded
+// (>) block scalars, the flow sequences of scalars ([a, b]) and comments.
+// the anchors, tags and flow mappings are not supported.
+func decodeYAMLTree(data string) (map[string]any, error) {
+	decoder := &yamlDecoder{}
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "---" || trimmed == "..." {
+			continue
+		}
+
+		decoder.lines = append(decoder.lines, yamlLine{
+			indent: len(line) - len(strings.TrimLeft(line, " ")),
+			text:   trimmed,
+			raw:    line,
+			lineNo: i + 1,
+		})
+	}
+
+	decoder.skipEmptyLines()
+	if decoder.pos >= len(decoder.lines) {
+		return make(map[string]any), nil
+	}
+
+	value, err := decoder.decodeBlock(decoder.lines[decoder.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("yaml: the document must be a mapping")
+	} else if decoder.pos < len(decoder.lines) {
+		return nil, decoder.errorf("unexpected indentation")
+	}
+
+	return tree, nil
+}
+
+// decodeBlock decodes the mapping or the sequence which starts at the
+// current line, with the given indentation.
+func (d *yamlDecoder) decodeBlock(indent int) (any, error) {
+	if isYAMLSequenceItem(d.lines[d.pos].text) {
+		return d.decodeSequence(indent)
+	}
+
+	return d.decodeMapping(indent)
+}
+
+func (d *yamlDecoder) decodeSequence(indent int) ([]any, error) {
+	var values []any
+	for d.skipEmptyLines(); d.pos < len(d.lines); d.skipEmptyLines() {
+		line := &d.lines[d.pos]
+		if line.indent < indent || !isYAMLSequenceItem(line.text) {
+			break
+		} else if line.indent > indent {
+			return nil, d.errorf("unexpected indentation")
+		}
+
+		rest := strings.TrimSpace(strings.TrimPrefix(line.text, "-"))
+		if rest == "" {
+			d.pos++
+			value, err := d.decodeNested(indent)
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, value)
+			continue
+		}
+
+		if _, _, isPair := splitYAMLPair(rest); isPair || isYAMLSequenceItem(rest) {
+			// the item is a mapping (or a sequence) which starts on the line
+			// of the dash; its indentation is the position of its text.
+			itemIndent := line.indent + len(line.text) - len(rest)
+			line.indent = itemIndent
+			line.text = rest
+			value, err := d.decodeBlock(itemIndent)
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, value)
+			continue
+		}
+
+		value, err := parseYAMLScalar(rest)
+		if err != nil {
+			return nil, d.errorf("%v", err)
+		}
+
+		values = append(values, value)
+		d.pos++
+	}
+
+	return values, nil
+}
+
+func (d *yamlDecoder) decodeMapping(indent int) (map[string]any, error) {
+	values := make(map[string]any)
+	for d.skipEmptyLines(); d.pos < len(d.lines); d.skipEmptyLines() {
+		line := d.lines[d.pos]
+		if line.indent < indent {
+			break
+		} else if line.indent > indent {
+			return nil, d.errorf("unexpected indentation")
+		}
+
+		key, rest, isPair := splitYAMLPair(line.text)
+		if !isPair {
+			return nil, d.errorf("expected a 'key: value' pair")
+		}
+
+		d.pos++
+		var value any
+		var err error
+		switch {
+		case rest == "":
+			value, err = d.decodeNested(indent)
+		case strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+			value = d.decodeBlockScalar(indent, rest)
+		default:
+			value, err = parseYAMLScalar(rest)
+			if err != nil {
+				err = fmt.Errorf("yaml: line %d: %w", line.lineNo, err)
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+// decodeNested decodes the value of a key (or of a sequence item) which has
+// nothing after its colon (or dash); the value is nil if there is no nested
+// block after it.
+func (d *yamlDecoder) decodeNested(indent int) (any, error) {
+	d.skipEmptyLines()
+	if d.pos >= len(d.lines) {
+		return nil, nil
+	}
+
+	next := d.lines[d.pos]
+	if next.indent > indent || (next.indent == indent && isYAMLSequenceItem(next.text)) {
+		return d.decodeBlock(next.indent)
+	}
+
+	return nil, nil
+}
+
+// decodeBlockScalar decodes the literal (|) or folded (>) block scalar which
+// follows a key with the given indentation.
+func (d *yamlDecoder) decodeBlockScalar(indent int, header string) string {
+	var lines []string
+	blockIndent := -1
+	for ; d.pos < len(d.lines); d.pos++ {
+		line := d.lines[d.pos]
+		if line.raw == "" {
+			lines = append(lines, "")
+			continue
+		} else if line.indent <= indent {
+			break
+		}
+
+		if blockIndent == -1 {
+			blockIndent = line.indent
+		}
+
+		lines = append(lines, line.raw[min(blockIndent, line.indent):])
+	}
+
+	// the trailing empty lines don't belong to the scalar.
+	for len(lines) != 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		d.pos--
+	}
+
+	separator := "\n"
+	if strings.HasPrefix(header, ">") {
+		separator = " "
+	}
+
+	value := strings.Join(lines, separator)
+	if !strings.Contains(header, "-") {
+		value += "\n"
+	}
+
+	return value
+}
+
+// skipEmptyLines skips the empty lines and the comment lines.
+func (d *yamlDecoder) skipEmptyLines() {
+	for d.pos < len(d.lines) {
+		text := d.lines[d.pos].text
+		if text != "" && !strings.HasPrefix(text, "#") {
+			return
+		}
+
+		d.pos++
+	}
+}
+
+func (d *yamlDecoder) errorf(format string, args ...any) error {
+	lineNo := 0
+	if d.pos < len(d.lines) {
+		lineNo = d.lines[d.pos].lineNo
+	}
+
+	return fmt.Errorf("yaml: line %d: %s", lineNo, fmt.Sprintf(format, args...))
+}
+
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLPair splits the "key: value" pair; the key may be quoted.
+func splitYAMLPair(text string) (key, value string, ok bool) {
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		end := strings.Index(text[1:], text[:1])
+		if end == -1 || !strings.HasPrefix(text[end+2:], ":") {
+			return "", "", false
+		}
+
+		key, value = text[1:end+1], text[end+3:]
+	} else {
+		index := strings.Index(text, ": ")
+		if strings.HasSuffix(text, ":") && (index == -1 || index == len(text)-1) {
+			index = len(text) - 1
+		}
+
+		if index <= 0 || strings.HasPrefix(text, "#") {
+			return "", "", false
+		}
+
+		key, value = text[:index], text[index+1:]
+	}
+
+	if value != "" && value[0] != ' ' && value[0] != '\t' {
+		return "", "", false
+	}
+
+	return strings.TrimSpace(key), stripYAMLComment(strings.TrimSpace(value)), true
+}
+
+// stripYAMLComment removes the comment at the end of a plain scalar.
+func stripYAMLComment(value string) string {
+	if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+		quote := value[:1]
+		for i := 1; i < len(value); i++ {
+			if value[i] == '\\' && quote == `"` {
+				i++
+			} else if value[i] == quote[0] {
+				return strings.TrimSpace(value[:i+1] + stripYAMLComment(value[i+1:]))
+			}
+		}
+
+		return value
+	}
+
+	return strings.TrimSpace(stripInlineComment(value, []string{"#"}))
+}
+
+// parseYAMLScalar parses a plain, quoted or null scalar, or a flow sequence
+// of scalars.
+func parseYAMLScalar(value string) (any, error) {
+	switch {
+	case value == "~" || value == "null":
+		return nil, nil
+	case value == "true" || value == "false":
+		return value == "true", nil
+	case strings.HasPrefix(value, `"`):
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid double-quoted scalar: %s", value)
+		}
+
+		return unquoted, nil
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return nil, fmt.Errorf("invalid single-quoted scalar: %s", value)
+		}
+
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	case strings.HasPrefix(value, "["):
+		if !strings.HasSuffix(value, "]") {
+			return nil, fmt.Errorf("invalid flow sequence: %s", value)
+		}
+
+		var values []any
+		for _, current := range strings.Split(value[1:len(value)-1], ",") {
+			current = strings.TrimSpace(current)
+			if current == "" {
+				continue
+			}
+
+			elem, err := parseYAMLScalar(current)
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, elem)
+		}
+
+		return values, nil
+	case strings.HasPrefix(value, "{"):
+		return nil, fmt.Errorf("flow mappings are not supported: %s", value)
+	}
+
+	return value, nil
+}
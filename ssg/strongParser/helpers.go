@@ -1,13 +1,19 @@
 package strongParser
 
 import (
-	"bufio"
+	"bytes"
+	"cmp"
+	"encoding"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
-	"unicode"
+	"sync"
 
 	"github.com/AnimeKaizoku/ssg/ssg/internal"
 )
@@ -45,41 +51,288 @@ func NewConfigParserFromFile(filename string) (*ConfigParser, error) {
 
 // Parse takes a filename and parses it into a ConfigParser value.
 func Parse(filename string) (*ConfigParser, error) {
+	return ParseWithOptions(filename, nil)
+}
+
+// ParseWithOptions takes a filename and parses it into a ConfigParser value,
+// using the given options.
+//
+// the values are taken from these layers, each one overriding the previous
+// ones: the file itself, the files of its include directives (in order),
+// the drop-in files of the DropInDir (in the order of their names), the
+// environment variables (if EnvOverrides is true) and the command-line
+// flags (if ReadFlags is true). GetOrigin returns the layer which supplied
+// the value of an option.
+func ParseWithOptions(filename string, opt *ConfigParserOptions) (*ConfigParser, error) {
 	virtualFlag := strings.Contains(filename, ":virtual")
 	if virtualFlag {
 		filename = strings.ReplaceAll(filename, ":virtual", "")
 	}
 
-	file, err := os.Open(filename)
+	p, err := parseFileLayer(filename, opt, nil, 0)
 	if err != nil {
-		if virtualFlag {
-			// don't complain on virtual file
-			return parseString("")
+		if !virtualFlag || !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		// don't complain on virtual file
+		p, err = parseString("", opt)
+		if err != nil {
+			return nil, err
 		}
+	}
+
+	err = p.applyLayers(filepath.Dir(filename))
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// parseFileLayer parses the file and merges the files of its include
+// directives into it; visited contains the absolute paths of the files
+// which are being parsed, so the include cycles can be detected.
+func parseFileLayer(
+	filename string,
+	opt *ConfigParserOptions,
+	visited map[string]bool,
+	depth int,
+) (*ConfigParser, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("more than %d nested included files: %s", maxIncludeDepth, filename)
+	}
+
+	absPath, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
 
+	if visited == nil {
+		visited = make(map[string]bool)
+	} else if visited[absPath] {
+		return nil, fmt.Errorf("include cycle: %s", filename)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
 		return nil, err
 	}
 
-	defer func() {
-		if file != nil {
-			_ = file.Close()
+	p, err := GetFormat(filename).Decode(content, opt)
+	if err != nil {
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) {
+			syntaxErr.setFile(filename)
+			return nil, err
 		}
-	}()
-	p, err := parseFile(file)
+
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	p.setOriginsFile(filename)
+	p.files = append(p.files, filename)
+	visited[absPath] = true
+	defer delete(visited, absPath)
+
+	err = p.loadIncludes(filepath.Dir(filename), visited, depth)
 	if err != nil {
 		return nil, err
 	}
+
 	return p, nil
 }
 
+// Watch parses the config file into value, then re-parses it whenever the
+// file (or any of its layers) changes, until the watcher is stopped. the
+// files are polled, and the Notify channel of the options can be used to
+// check them immediately. the reloaded configs are new values, so the
+// watcher's Get method should be used to get the current config; value
+// itself is only the first config.
+func Watch[T any](path string, value *T, opts *WatchOptions[T]) (*ConfigWatcher[T], error) {
+	if opts == nil {
+		opts = &WatchOptions[T]{}
+	}
+
+	watcher := &ConfigWatcher[T]{
+		path:     path,
+		options:  opts,
+		stamps:   make(map[string]fileStamp),
+		mutex:    &sync.Mutex{},
+		stopOnce: &sync.Once{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	p, err := ParseWithOptions(path, opts.ParserOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	err = parseFinalConfig(value, "", p)
+	if err != nil {
+		return nil, err
+	}
+
+	watcher.current.Store(value)
+	watcher.updateStamps(append([]string{path}, p.files...))
+
+	go watcher.run()
+	return watcher, nil
+}
+
+// getFileStamp returns the current state of the file.
+func getFileStamp(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+
+	return fileStamp{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		exists:  true,
+	}
+}
+
+// diffConfigValues appends the changes of the fields of the old and the new
+// values to changes; the nested structs are compared field by field.
+func diffConfigValues(path string, oldValue, newValue reflect.Value, changes *[]FieldChange) {
+	valueType := oldValue.Type()
+	if valueType.Kind() == reflect.Ptr && getStructType(valueType) != nil &&
+		!oldValue.IsNil() && !newValue.IsNil() {
+		oldValue, newValue = oldValue.Elem(), newValue.Elem()
+		valueType = oldValue.Type()
+	}
+
+	if valueType.Kind() != reflect.Struct || getStructType(valueType) == nil {
+		if !reflect.DeepEqual(oldValue.Interface(), newValue.Interface()) {
+			*changes = append(*changes, FieldChange{
+				Field:    path,
+				OldValue: oldValue.Interface(),
+				NewValue: newValue.Interface(),
+			})
+		}
+
+		return
+	}
+
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldPath := field.Name
+		if path != "" {
+			fieldPath = path + "." + field.Name
+		}
+
+		if isSecretField(field) {
+			// only the fact that the secret has changed is reported.
+			oldField, newField := oldValue.Field(i), newValue.Field(i)
+			if !reflect.DeepEqual(oldField.Interface(), newField.Interface()) {
+				*changes = append(*changes, FieldChange{
+					Field:    fieldPath,
+					OldValue: SecretMask,
+					NewValue: SecretMask,
+				})
+			}
+
+			continue
+		}
+
+		diffConfigValues(fieldPath, oldValue.Field(i), newValue.Field(i), changes)
+	}
+}
+
+// parseStringLayers parses the INI value, applying its include directives
+// (relative to the BaseDir option) and the layers of the options.
+func parseStringLayers(value string, opt *ConfigParserOptions) (*ConfigParser, error) {
+	return parseLayers([]byte(value), INIFormat, opt)
+}
+
+// parseLayers decodes the data of the format, applying its include
+// directives and drop-in files (relative to the BaseDir option, they aren't
+// loaded without it) and the other layers of the options.
+func parseLayers(data []byte, format ConfigFormat, opt *ConfigParserOptions) (*ConfigParser, error) {
+	p, err := format.Decode(data, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	var baseDir string
+	if opt != nil {
+		baseDir = opt.BaseDir
+	}
+
+	if baseDir == "" && len(p.includes) != 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoBaseDir, p.includes[0])
+	}
+
+	err = p.loadIncludes(baseDir, make(map[string]bool), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.applyLayers(baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// isGlobPattern returns true if the pattern contains any of the special
+// characters of filepath.Match.
+func isGlobPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// getEnvOverrideKey returns the name of the environment variable which
+// overrides the option of the section.
+func getEnvOverrideKey(section, key string) string {
+	name := strings.ToUpper(section + "_" + key)
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+
+		return '_'
+	}, name)
+}
+
+// parseFlag parses the "--name=value" flag; a flag without a value, such as
+// "--name", has the "true" value.
+func parseFlag(arg string) (name, value string, ok bool) {
+	if !strings.HasPrefix(arg, "--") || len(arg) == 2 {
+		return "", "", false
+	}
+
+	name, value, found := strings.Cut(arg[2:], "=")
+	if !found {
+		value = "true"
+	}
+
+	return name, value, name != ""
+}
+
 // ParseBytes takes bytes array and parses it into a ConfigParser value.
 func ParseBytes(b []byte) (*ConfigParser, error) {
-	return parseBytes(b)
+	return parseStringLayers(string(b), nil)
 }
 
-// ParseBytes takes bytes array and parses it into a ConfigParser value.
+// ParseString takes a string and parses it into a ConfigParser value.
 func ParseString(value string) (*ConfigParser, error) {
-	return parseString(value)
+	return parseStringLayers(value, nil)
+}
+
+// ParseStringWithOptions takes a string and parses it into a ConfigParser
+// value, using the given options; see ParseWithOptions for the layers of
+// the values. the include directives and the drop-in files are only loaded
+// if the BaseDir option is set.
+func ParseStringWithOptions(value string, opt *ConfigParserOptions) (*ConfigParser, error) {
+	return parseStringLayers(value, opt)
 }
 
 func ParseConfig(value any, filename string) error {
@@ -87,7 +340,7 @@ func ParseConfig(value any, filename string) error {
 }
 
 func ParseConfigWithOption(value any, filename string, opt *ConfigParserOptions) error {
-	p, err := Parse(filename)
+	p, err := ParseWithOptions(filename, opt)
 	if err != nil {
 		if opt == nil || !opt.ReadEnv {
 			return err
@@ -101,7 +354,7 @@ func ParseConfigWithOption(value any, filename string, opt *ConfigParserOptions)
 }
 
 func ParseMainAndArrays[mT any, aT any](filename string, opt *ConfigParserOptions) (*MainAndArrayContainer[mT, aT], error) {
-	p, err := Parse(filename)
+	p, err := ParseWithOptions(filename, opt)
 	if err != nil {
 		if opt == nil && !opt.ReadEnv {
 			return nil, err
@@ -115,7 +368,7 @@ func ParseMainAndArrays[mT any, aT any](filename string, opt *ConfigParserOption
 }
 
 func ParseMainAndArraysStr[mT any, aT any](valueStr string, opt *ConfigParserOptions) (*MainAndArrayContainer[mT, aT], error) {
-	p, err := parseString(valueStr)
+	p, err := parseStringLayers(valueStr, opt)
 	if err != nil {
 		if opt == nil && !opt.ReadEnv {
 			return nil, err
@@ -138,11 +391,10 @@ func parseMainAndArrays[mT any, aT any](p *ConfigParser) (*MainAndArrayContainer
 		Main: new(mT),
 	}
 
-	for _, current := range p.config {
-		if current == nil {
-			continue
-		} else if current.Name == p.options.MainSectionName {
-			err = parseFinalConfig(container.Main, current.Name, p)
+	var sections []string
+	for _, current := range p.Sections() {
+		if current == p.options.MainSectionName {
+			err = parseFinalConfig(container.Main, current, p)
 			if err != nil {
 				return nil, err
 			}
@@ -150,25 +402,28 @@ func parseMainAndArrays[mT any, aT any](p *ConfigParser) (*MainAndArrayContainer
 			continue
 		}
 
-		var currentSection = new(aT)
-		err = parseFinalConfig(currentSection, current.Name, p)
-		if err != nil {
-			return nil, err
-		}
+		sections = append(sections, current)
+	}
 
-		validS, ok := interface{}(currentSection).(SectionValue)
-		if ok {
-			validS.SetSectionName(current.Name)
-		}
+	p.fieldErrors = nil
+	err = parseSectionSlice(reflect.ValueOf(&container.Sections).Elem(), sections, p, 0)
+	if err != nil {
+		return nil, err
+	} else if err = p.getConfigError(); err != nil {
+		return nil, err
+	}
 
-		container.Sections = append(container.Sections, currentSection)
+	for _, current := range container.Sections {
+		if err = validateConfig(current); err != nil {
+			return nil, err
+		}
 	}
 
 	return container, nil
 }
 
 func ParseByteConfig(value any, b []byte) error {
-	p, err := parseBytes(b)
+	p, err := parseStringLayers(string(b), nil)
 	if err != nil {
 		return err
 	}
@@ -177,7 +432,7 @@ func ParseByteConfig(value any, b []byte) error {
 }
 
 func ParseStringConfig(value any, strValue string) error {
-	p, err := parseString(strValue)
+	p, err := parseStringLayers(strValue, nil)
 	if err != nil {
 		return err
 	}
@@ -186,7 +441,7 @@ func ParseStringConfig(value any, strValue string) error {
 }
 
 func ParseStringConfigWithOption(value any, strValue string, opt *ConfigParserOptions) error {
-	p, err := parseString(strValue)
+	p, err := parseStringLayers(strValue, opt)
 	if err != nil {
 		return err
 	}
@@ -196,23 +451,236 @@ func ParseStringConfigWithOption(value any, strValue string, opt *ConfigParserOp
 	return parseFinalConfig(value, "", p)
 }
 
+// MarshalConfig is the inverse of ParseConfig: it returns the INI encoding of
+// the given struct (or pointer to struct), honouring the same tags. the
+// secret fields which have been parsed from secret references (such as
+// "file:///run/secrets/token") are written as their references, and the
+// other secret fields as their values, so the output can be parsed back;
+// use DumpConfig for printing the config.
+func MarshalConfig(value any) ([]byte, error) {
+	p, err := marshalConfigParser(value, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return writeMarshaledConfig(p)
+}
+
+// DumpConfig is the same as MarshalConfig, except that the values of the
+// secret fields are masked; it's meant for printing or logging the config,
+// since the output can't be parsed back into the same config.
+func DumpConfig(value any) ([]byte, error) {
+	p, err := marshalConfigParser(value, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return writeMarshaledConfig(p)
+}
+
+// writeMarshaledConfig returns the INI encoding of the marshaled config.
+func writeMarshaledConfig(p *ConfigParser) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	_, err := p.WriteTo(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// MarshalConfigParser returns a new ConfigParser containing the values of the
+// given struct (or pointer to struct), so it can be changed or saved to a file.
+// the actual values of the fields are written, even if they're zero and have
+// a `default` tag.
+func MarshalConfigParser(value any) (*ConfigParser, error) {
+	return marshalConfigParser(value, false)
+}
+
+// marshalConfigParser returns a new ConfigParser containing the values of the
+// struct; maskSecrets masks the values of the secret fields.
+func marshalConfigParser(value any, maskSecrets bool) (*ConfigParser, error) {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil, &InvalidParseError{reflect.TypeOf(value)}
+	}
+
+	p := NewConfigParser()
+	err := marshalStructValue(rv, "", p, maskSecrets, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// marshalStructValue puts the fields of the struct value into the parser;
+// it's the inverse of parseStructValue.
+func marshalStructValue(rv reflect.Value, section string, p *ConfigParser, maskSecrets bool, depth int) error {
+	if depth > maxStructDepth {
+		return fmt.Errorf("strongParser: more than %d nested structs in %s", maxStructDepth, rv.Type())
+	}
+
+	myType := rv.Type()
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Field(i)
+		fByName := myType.Field(i)
+		if !fByName.IsExported() && !fByName.Anonymous {
+			continue
+		}
+
+		fType := fByName.Type
+		switch {
+		case getStructType(fType) != nil:
+			if fType.Kind() == reflect.Ptr {
+				if field.IsNil() {
+					continue
+				}
+
+				field = field.Elem()
+			}
+
+			nestedSection := section
+			if !fByName.Anonymous {
+				nestedSection = getNestedSectionName(fByName)
+				p.getOrAddSection(nestedSection)
+			}
+
+			err := marshalStructValue(field, nestedSection, p, maskSecrets, depth+1)
+			if err != nil {
+				return err
+			}
+		case fType.Kind() == reflect.Map && fType.Key().Kind() == reflect.String:
+			if field.IsNil() {
+				continue
+			}
+
+			mapSection := p.getOrAddSection(getNestedSectionName(fByName))
+			keys := field.MapKeys()
+			sort.Slice(keys, func(i, j int) bool {
+				return keys[i].String() < keys[j].String()
+			})
+
+			for _, key := range keys {
+				value, ok := formatFieldValue(field.MapIndex(key), "")
+				if isSecretField(fByName) {
+					value = formatSecretField(value, maskSecrets)
+				}
+
+				if ok {
+					mapSection.Add(key.String(), value)
+				}
+			}
+		case fType.Kind() == reflect.Slice && getStructType(fType.Elem()) != nil:
+			prefix := getNestedSectionName(fByName) + "."
+			for j := 0; j < field.Len(); j++ {
+				elem := field.Index(j)
+				if elem.Kind() == reflect.Ptr {
+					if elem.IsNil() {
+						continue
+					}
+
+					elem = elem.Elem()
+				}
+
+				elemSection := prefix + strconv.Itoa(j+1)
+				validS, ok := elem.Addr().Interface().(SectionValue)
+				if ok && validS.GetSectionName() != "" {
+					elemSection = validS.GetSectionName()
+				}
+
+				p.getOrAddSection(elemSection)
+				err := marshalStructValue(elem, elemSection, p, maskSecrets, depth+1)
+				if err != nil {
+					return err
+				}
+			}
+		default:
+			if !fByName.IsExported() {
+				continue
+			}
+
+			currentSection := section
+			if currentSection == "" {
+				currentSection = fByName.Tag.Get("section")
+			}
+
+			if currentSection == "" {
+				currentSection = DefaultMainSection
+			}
+
+			key := fByName.Tag.Get("key")
+			if key == "" {
+				key = toSnakeCase(fByName.Name)
+			}
+
+			value, ok := formatFieldValue(field, strings.ToLower(fByName.Tag.Get("type")))
+
+			if isSecretField(fByName) {
+				value = formatSecretField(value, maskSecrets)
+			}
+
+			if ok {
+				p.getOrAddSection(currentSection).Add(key, value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// getDefaultOptions returns the options which are used to parse a struct when
+// no options are given.
+func getDefaultOptions() *ConfigParserOptions {
+	return &ConfigParserOptions{
+		ReadEnv:         true,
+		MainSectionName: DefaultMainSection,
+	}
+}
+
 func parseFinalConfig(v any, section string, configValue *ConfigParser) error {
 	if configValue.options == nil {
-		configValue.options = &ConfigParserOptions{
-			ReadEnv:         true,
-			MainSectionName: DefaultMainSection,
-		}
+		configValue.options = getDefaultOptions()
 	}
 
 	rv := reflect.ValueOf(v)
-	myType := reflect.TypeOf(v)
-	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
 		return &InvalidParseError{reflect.TypeOf(v)}
 	}
 
-	rv = rv.Elem()
-	myType = myType.Elem()
+	configValue.fieldErrors = nil
+	err := parseStructValue(rv.Elem(), section, configValue, 0)
+	if err != nil {
+		return err
+	} else if err = configValue.getConfigError(); err != nil {
+		return err
+	}
 
+	return validateConfig(v)
+}
+
+// validateConfig calls the Validate method of the value, if it implements
+// the Validator interface.
+func validateConfig(v any) error {
+	if validator, ok := v.(Validator); ok {
+		return validator.Validate()
+	}
+
+	return nil
+}
+
+// parseStructValue sets the fields of the struct value from the given section;
+// depth is the count of the nested structs we are in.
+func parseStructValue(rv reflect.Value, section string, configValue *ConfigParser, depth int) error {
+	if depth > maxStructDepth {
+		return fmt.Errorf("strongParser: more than %d nested structs in %s", maxStructDepth, rv.Type())
+	}
+
+	myType := rv.Type()
 	var currentField reflect.Value
 	var shouldSkipCounter bool
 	var currentIndex = -1
@@ -224,6 +692,21 @@ func parseFinalConfig(v any, section string, configValue *ConfigParser) error {
 				break
 			}
 			currentField = rv.Field(currentIndex)
+
+			handled, err := parseCompositeField(
+				myType,
+				currentField,
+				myType.Field(currentIndex),
+				section,
+				configValue,
+				depth,
+			)
+			if err != nil {
+				return err
+			} else if handled {
+				configValue.validateField(myType, myType.Field(currentIndex), currentField, section)
+				continue
+			}
 		} else {
 			shouldSkipCounter = false
 		}
@@ -234,8 +717,6 @@ func parseFinalConfig(v any, section string, configValue *ConfigParser) error {
 		}
 
 		switch currentField.Kind() {
-		case reflect.Struct:
-			continue
 		case reflect.Ptr:
 			fByName := myType.Field(currentIndex)
 			if !fByName.IsExported() {
@@ -258,14 +739,14 @@ func parseFinalConfig(v any, section string, configValue *ConfigParser) error {
 				continue
 			}
 
-			strValue := extractFieldValue(
+			strValue, found := extractFieldValue(
 				configValue,
 				myType,
 				currentIndex,
 				section,
 				extractStr,
 			)
-			if strValue != "" {
+			if found {
 				currentField.SetString(strValue)
 			}
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -274,14 +755,14 @@ func parseFinalConfig(v any, section string, configValue *ConfigParser) error {
 				continue
 			}
 
-			intValue := extractFieldValue(
+			intValue, found := extractFieldValue(
 				configValue,
 				myType,
 				currentIndex,
 				section,
 				extractInt64,
 			)
-			if intValue != 0 {
+			if found {
 				currentField.SetInt(intValue)
 			}
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
@@ -290,14 +771,14 @@ func parseFinalConfig(v any, section string, configValue *ConfigParser) error {
 				continue
 			}
 
-			uintValue := extractFieldValue(
+			uintValue, found := extractFieldValue(
 				configValue,
 				myType,
 				currentIndex,
 				section,
 				extractUInt64,
 			)
-			if uintValue != 0 {
+			if found {
 				currentField.SetUint(uintValue)
 			}
 		case reflect.Bool:
@@ -306,14 +787,14 @@ func parseFinalConfig(v any, section string, configValue *ConfigParser) error {
 				continue
 			}
 
-			boolValue := extractFieldValue(
+			boolValue, found := extractFieldValue(
 				configValue,
 				myType,
 				currentIndex,
 				section,
 				extractBool,
 			)
-			if boolValue {
+			if found {
 				currentField.SetBool(boolValue)
 			}
 		case reflect.Float32, reflect.Float64:
@@ -322,14 +803,14 @@ func parseFinalConfig(v any, section string, configValue *ConfigParser) error {
 				continue
 			}
 
-			floatValue := extractFieldValue(
+			floatValue, found := extractFieldValue(
 				configValue,
 				myType,
 				currentIndex,
 				section,
 				extractFloat64,
 			)
-			if floatValue != 0 {
+			if found {
 				currentField.SetFloat(floatValue)
 			}
 		case reflect.Complex64, reflect.Complex128:
@@ -338,14 +819,14 @@ func parseFinalConfig(v any, section string, configValue *ConfigParser) error {
 				continue
 			}
 
-			complexValue := extractFieldValue(
+			complexValue, found := extractFieldValue(
 				configValue,
 				myType,
 				currentIndex,
 				section,
 				extractComplex128,
 			)
-			if complexValue != 0 {
+			if found {
 				currentField.SetComplex(complexValue)
 			}
 		case reflect.Array, reflect.Slice:
@@ -373,21 +854,464 @@ func parseFinalConfig(v any, section string, configValue *ConfigParser) error {
 			envKey := fByName.Tag.Get("env")
 			isRune := fType == "rune" || fType == "[]rune"
 
+			if configValue.isStrict() {
+				rawValue, source := configValue.getArrayRawValue(currentSection, key, envKey)
+				err := checkArrayValue(rawValue, currentField.Type().Elem(), isRune)
+				if err != nil {
+					configValue.addConversionError(
+						myType, fByName,
+						currentSection, key,
+						source, rawValue, err,
+					)
+				}
+			}
+
 			valueToSet, err := configValue.getArrayValueToSet(
 				currentSection, key, envKey,
 				myKind, isRune,
 			)
-			if err != nil || valueToSet.IsNil() || !valueToSet.IsValid() {
-				continue
+			if err == nil && !valueToSet.IsNil() && valueToSet.IsValid() {
+				currentField.Set(valueToSet)
 			}
+		}
+
+		if fByName := myType.Field(currentIndex); fByName.IsExported() {
+			configValue.validateField(myType, fByName, currentField, section)
+		}
+	}
+
+	return nil
+}
+
+// checkFieldLimit returns an error if the value of the field (or its length,
+// for the strings, slices and maps) is out of the limit of the `min` (or the
+// `max`) tag. the limits of the ordered types which have a converter (such
+// as time.Duration and time.Time) are converted the same way as the values,
+// such as `min:"1s"`.
+func checkFieldLimit(field rValue, limit string, isMin bool) error {
+	tagName := "max"
+	if isMin {
+		tagName = "min"
+	}
+
+	// result is the comparison of the value to the limit.
+	var result int
+	var err error
+	what := "value"
+	if converter := getValueConverter(field.Type()); converter != nil && isOrderedType(field.Type()) {
+		result, err = compareConvertedLimit(field, converter, limit)
+	} else {
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			result, err = compareIntLimit(field.Int(), limit)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			result, err = compareUintLimit(field.Uint(), limit)
+		case reflect.Float32, reflect.Float64:
+			result, err = compareFloatLimit(field.Float(), limit)
+		case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+			result, err = compareIntLimit(int64(field.Len()), limit)
+			what = "length"
+		default:
+			return nil
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid %s tag: '%s'", tagName, limit)
+	}
+
+	if isMin && result < 0 {
+		return fmt.Errorf("%s must be at least %s", what, limit)
+	} else if !isMin && result > 0 {
+		return fmt.Errorf("%s must be at most %s", what, limit)
+	}
+
+	return nil
+}
+
+// isOrderedType returns true if the values of the type are numbers, or if the
+// type has a "Compare(T) int" method (like time.Time).
+func isOrderedType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	method, ok := t.MethodByName("Compare")
+	return ok && method.Type.NumIn() == 2 && method.Type.In(1) == t &&
+		method.Type.NumOut() == 1 && method.Type.Out(0).Kind() == reflect.Int
+}
+
+// compareConvertedLimit converts the limit to the type of the field, and
+// compares the value of the field to it; the type should be ordered.
+func compareConvertedLimit(field rValue, converter valueConverter, limit string) (int, error) {
+	limitValue, err := converter("", limit)
+	if err != nil {
+		return 0, err
+	}
+
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(field.Int(), limitValue.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp.Compare(field.Uint(), limitValue.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(field.Float(), limitValue.Float()), nil
+	}
+
+	return int(field.MethodByName("Compare").Call([]rValue{limitValue})[0].Int()), nil
+}
+
+// compareIntLimit compares the value to the limit; the integer limits are
+// compared as integers, so the large values don't lose their precision.
+func compareIntLimit(value int64, limit string) (int, error) {
+	if limitValue, err := strconv.ParseInt(limit, 10, 64); err == nil {
+		return cmp.Compare(value, limitValue), nil
+	}
+
+	return compareFloatLimit(float64(value), limit)
+}
+
+func compareUintLimit(value uint64, limit string) (int, error) {
+	if limitValue, err := strconv.ParseUint(limit, 10, 64); err == nil {
+		return cmp.Compare(value, limitValue), nil
+	}
+
+	return compareFloatLimit(float64(value), limit)
+}
+
+func compareFloatLimit(value float64, limit string) (int, error) {
+	limitValue, err := strconv.ParseFloat(limit, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return cmp.Compare(value, limitValue), nil
+}
+
+// parseCompositeField parses the field if it has a converter (or it's a
+// pointer or a slice of such a type), if it's a struct (or a pointer to it),
+// a map or a slice of structs; handled will be false if the field is of
+// another kind.
+func parseCompositeField(
+	myType reflect.Type,
+	field reflect.Value,
+	fByName reflect.StructField,
+	section string,
+	configValue *ConfigParser,
+	depth int,
+) (handled bool, err error) {
+	fType := fByName.Type
+	switch {
+	case getConvertibleType(fType) != nil:
+		return true, parseConvertibleField(myType, field, fByName, section, configValue)
+	case getStructType(fType) != nil:
+		return true, parseStructField(field, fByName, section, configValue, depth)
+	case fType.Kind() == reflect.Map && fType.Key().Kind() == reflect.String:
+		if !field.CanSet() {
+			return true, nil
+		}
+
+		// the whole section is used as the map.
+		nestedSection := getNestedSectionName(fByName)
+		if !configValue.HasSection(nestedSection) {
+			return true, nil
+		}
+
+		valueToSet, err := configValue.getMapValueToSet(myType, fByName, nestedSection)
+		if err != nil {
+			return true, err
+		}
+
+		field.Set(valueToSet)
+		return true, nil
+	case fType.Kind() == reflect.Slice && getStructType(fType.Elem()) != nil:
+		if !field.CanSet() {
+			return true, nil
+		}
+
+		// each of the sections named "prefix.something" is an element.
+		prefix := getNestedSectionName(fByName) + "."
+		return true, parseSectionSlice(
+			field,
+			configValue.getSectionsWithPrefix(prefix),
+			configValue,
+			depth,
+		)
+	}
+
+	return false, nil
+}
+
+// parseStructField parses a struct field or a pointer to struct field.
+// embedded structs are flattened into the current section, the other structs
+// are parsed from their own section (the `section` tag or the snake-cased name
+// of the field). pointers to them are only allocated if that section exists.
+func parseStructField(
+	field reflect.Value,
+	fByName reflect.StructField,
+	section string,
+	configValue *ConfigParser,
+	depth int,
+) error {
+	fType := getStructType(fByName.Type)
+	isPointer := fByName.Type.Kind() == reflect.Ptr
+
+	if fByName.Anonymous {
+		if !isPointer {
+			// the exported fields of an unexported embedded struct can
+			// still be set.
+			return parseStructValue(field, section, configValue, depth+1)
+		}
+
+		if !field.CanSet() {
+			return nil
+		} else if field.IsNil() {
+			field.Set(reflect.New(fType))
+		}
+
+		return parseStructValue(field.Elem(), section, configValue, depth+1)
+	}
+
+	if !field.CanSet() {
+		return nil
+	}
+
+	nestedSection := getNestedSectionName(fByName)
+	if !isPointer {
+		return parseStructValue(field, nestedSection, configValue, depth+1)
+	}
+
+	if !configValue.HasSection(nestedSection) {
+		return nil
+	} else if field.IsNil() {
+		field.Set(reflect.New(fType))
+	}
+
+	return parseStructValue(field.Elem(), nestedSection, configValue, depth+1)
+}
+
+// parseSectionSlice sets the slice field to the structs parsed from each of
+// the given sections; the elements of the slice may be structs or pointers
+// to them. if an element implements SectionValue, its section name is set.
+// the field is left as it is if there are no sections.
+func parseSectionSlice(field reflect.Value, sections []string, configValue *ConfigParser, depth int) error {
+	elemType := field.Type().Elem()
+	structType := getStructType(elemType)
+	if structType == nil {
+		return &InvalidParseError{elemType}
+	} else if len(sections) == 0 {
+		return nil
+	}
+
+	result := reflect.MakeSlice(field.Type(), 0, len(sections))
+	for _, current := range sections {
+		value := reflect.New(structType)
+		err := parseStructValue(value.Elem(), current, configValue, depth+1)
+		if err != nil {
+			return err
+		}
+
+		validS, ok := value.Interface().(SectionValue)
+		if ok {
+			validS.SetSectionName(current)
+		}
+
+		if elemType.Kind() == reflect.Ptr {
+			result = reflect.Append(result, value)
+		} else {
+			result = reflect.Append(result, value.Elem())
+		}
+	}
+
+	field.Set(result)
+	return nil
+}
+
+// getStructType returns the struct type if t is a struct or a pointer to a
+// struct, otherwise nil.
+func getStructType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct || getValueConverter(t) != nil {
+		// the structs with a converter (such as time.Time) are values.
+		return nil
+	}
+
+	return t
+}
+
+// parseConvertibleField parses the field which has a converter, or the
+// pointer or slice of such a type. pointers are only allocated if there is
+// a value for the field.
+func parseConvertibleField(
+	myType reflect.Type,
+	field reflect.Value,
+	fByName reflect.StructField,
+	section string,
+	configValue *ConfigParser,
+) error {
+	if !field.CanSet() || !fByName.IsExported() {
+		return nil
+	}
+
+	fType := fByName.Type
+	elemType := getConvertibleType(fType)
+	converter := getValueConverter(elemType)
+	isSlice := fType != elemType && fType.Kind() == reflect.Slice
+
+	// the value is checked while it's being extracted, so the invalid values
+	// fall back to the other sources.
+	rawValue, found := extractStructFieldValue(
+		configValue,
+		myType,
+		fByName,
+		section,
+		func(tagType, value string) (string, error) {
+			_, err := convertFieldValue(converter, tagType, value, isSlice)
+			return value, err
+		},
+	)
+	if !found {
+		return nil
+	}
+
+	tagType := strings.ToLower(fByName.Tag.Get("type"))
+	valueToSet, err := convertFieldValue(converter, tagType, rawValue, isSlice)
+	if err != nil {
+		return nil
+	}
+
+	if fType.Kind() == reflect.Ptr && fType != elemType {
+		pointer := reflect.New(elemType)
+		pointer.Elem().Set(valueToSet)
+		valueToSet = pointer
+	} else if isSlice {
+		valueToSet = valueToSet.Convert(fType)
+	}
 
-			currentField.Set(valueToSet)
+	field.Set(valueToSet)
+	return nil
+}
+
+// convertFieldValue converts the value using the converter; if isSlice is
+// true, the value is split into the elements of a slice.
+func convertFieldValue(converter valueConverter, fType, value string, isSlice bool) (rValue, error) {
+	if !isSlice {
+		return converter(fType, value)
+	}
+
+	elemType := strings.TrimPrefix(fType, "[]")
+	var result rValue
+	for i, current := range splitArrayValue(value) {
+		elem, err := converter(elemType, current)
+		if err != nil {
+			return invalidReflectValue, err
+		}
+
+		if i == 0 {
+			result = reflect.MakeSlice(reflect.SliceOf(elem.Type()), 0, 1)
+		}
+
+		result = reflect.Append(result, elem)
+	}
+
+	if !result.IsValid() {
+		return invalidReflectValue, errors.New("no value found")
+	}
+
+	return result, nil
+}
+
+// splitArrayValue splits the value of an array option into its elements.
+func splitArrayValue(value string) []string {
+	var result []string
+	for _, current := range internal.SplitN(value, -1, ",", " ", "[", "]") {
+		current = strings.TrimSpace(current)
+		if current != "" {
+			result = append(result, current)
+		}
+	}
+
+	return result
+}
+
+// RegisterConverter registers the converter of the fields of type T (and of
+// the pointers and slices of T), which is used instead of the conversion by
+// the kind of the type; fType is the lower-cased `type` tag of the field.
+// the time.Duration, time.Time and url.URL types have converters by default,
+// and the types implementing encoding.TextUnmarshaler don't need one.
+func RegisterConverter[T any](converter func(fType, value string) (T, error)) {
+	customConvertersMutex.Lock()
+	customConverters[reflect.TypeFor[T]()] = toValueConverter(converter)
+	customConvertersMutex.Unlock()
+}
+
+// UnregisterConverter removes the converter of type T.
+func UnregisterConverter[T any]() {
+	customConvertersMutex.Lock()
+	delete(customConverters, reflect.TypeFor[T]())
+	customConvertersMutex.Unlock()
+}
+
+func toValueConverter[T any](converter func(fType, value string) (T, error)) valueConverter {
+	return func(fType, value string) (rValue, error) {
+		result, err := converter(fType, value)
+		return reflect.ValueOf(&result).Elem(), err
+	}
+}
+
+// getValueConverter returns the converter of the type; it's nil if the type
+// has no registered converter and doesn't implement encoding.TextUnmarshaler.
+func getValueConverter(t reflect.Type) valueConverter {
+	customConvertersMutex.RLock()
+	converter := customConverters[t]
+	customConvertersMutex.RUnlock()
+	if converter != nil {
+		return converter
+	}
+
+	if t.Kind() == reflect.Interface || !reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return nil
+	}
+
+	return func(_, value string) (rValue, error) {
+		result := reflect.New(t)
+		err := result.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+		return result.Elem(), err
+	}
+}
+
+// getConvertibleType returns the type which has a converter: the type
+// itself, or the element type of the pointer or the slice. it's nil if
+// none of them has a converter.
+func getConvertibleType(t reflect.Type) reflect.Type {
+	if getValueConverter(t) != nil {
+		return t
+	}
+
+	if t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		if getValueConverter(t.Elem()) != nil {
+			return t.Elem()
 		}
 	}
 
 	return nil
 }
 
+// getNestedSectionName returns the name of the section of a nested struct field.
+func getNestedSectionName(fByName reflect.StructField) string {
+	if name := fByName.Tag.Get("section"); name != "" {
+		return name
+	}
+
+	return toSnakeCase(fByName.Name)
+}
+
 func getArrayKind(t reflect.Type) reflect.Kind {
 	myStr := t.String()
 	if !strings.HasPrefix(myStr, "[]") {
@@ -566,90 +1490,269 @@ func getNoOptionError(section, option string) error {
 	return fmt.Errorf("no option '%s' in section: '%s'", option, section)
 }
 
-func parseFile(file *os.File) (*ConfigParser, error) {
+// getInterpolationKey returns the key which identifies the option while it's
+// being interpolated.
+func getInterpolationKey(section, option string) string {
+	return section + ":" + strings.ToLower(strings.TrimSpace(option))
+}
+
+func parseFile(file *os.File, opt *ConfigParserOptions) (*ConfigParser, error) {
+	return parseReader(file, opt)
+}
+
+func parseBytes(value []byte, opt *ConfigParserOptions) (*ConfigParser, error) {
+	return parseReader(bytes.NewReader(value), opt)
+}
+
+func parseString(value string, opt *ConfigParserOptions) (*ConfigParser, error) {
+	return parseReader(strings.NewReader(value), opt)
+}
+
+// parseReader parses the INI text of the reader into a ConfigParser, line by
+// line. the comments, blank lines and the original text of the options are
+// kept in the sections, so writing the parser back only changes the lines
+// of the modified options.
+//
+// the lines which are indented more than the line of an option are the
+// continuation of its value (like in Python's configparser), and a value
+// of a single line can be double-quoted to keep its whitespaces or to
+// use the escape sequences (such as \n, \t and \").
+//
+// the duplicate keys, the duplicate sections and the unrecognized lines are
+// kept in the parse errors of the parser (the last value of a duplicate key
+// is used); they are returned as a *SyntaxError if the StrictSyntax option
+// is true. the options before the first section header are always an error.
+func parseReader(r io.Reader, opt *ConfigParserOptions) (*ConfigParser, error) {
 	p := NewConfigParser()
+	p.options = opt
+	lexer := newINILexer(r)
 
-	reader := bufio.NewReader(file)
-	var lineNo int
-	var err error
 	var curSect *Section
+	var pending *pendingOption
+	var isFatal bool
+	seenSections := make(map[string]bool)
+	seenKeys := make(map[*Section]map[string]bool)
 
-	for err == nil {
-		l, _, err := reader.ReadLine()
-		if err != nil {
+	for {
+		token, ok := lexer.next()
+		if !ok {
 			break
 		}
-		lineNo++
-		if len(l) == 0 {
-			continue
-		}
-		line := strings.TrimFunc(string(l), unicode.IsSpace)
 
-		// Skip comment lines and empty lines
-		if strings.HasPrefix(line, "#") || line == "" {
-			continue
+		if pending != nil {
+			if token.kind != blankToken && token.indent > pending.indent {
+				pending.add(token.raw, strings.TrimSpace(token.raw))
+				continue
+			}
+
+			pending.finish(curSect, p.getInlineCommentPrefixes())
+			pending = nil
 		}
 
-		if match := sectionHeader.FindStringSubmatch(line); len(match) > 0 {
-			section := match[1]
-			if section == defaultSectionName {
-				curSect = p.defaults
-			} else if _, present := p.config[section]; !present {
-				curSect = newSection(section)
-				p.config[section] = curSect
+		switch token.kind {
+		case blankToken, commentToken:
+			// keep comment lines and empty lines as they are
+			p.addRawLine(curSect, token.raw)
+		case sectionToken:
+			if seenSections[token.name] {
+				p.addParseError(token, ErrDuplicateSection, token.name, "")
+			}
+
+			seenSections[token.name] = true
+			curSect = p.getOrAddSection(token.name)
+			if curSect.header == "" {
+				curSect.header = token.raw
+			}
+		case optionToken:
+			if curSect == nil && strings.EqualFold(token.name, includeDirective) {
+				include, _ := parseOptionValue([]string{token.value}, p.getInlineCommentPrefixes())
+				p.includes = append(p.includes, include)
+				p.addRawLine(curSect, token.raw)
+				continue
 			}
-		} else if match = keyValue.FindStringSubmatch(line); len(match) > 0 {
+
 			if curSect == nil {
-				return nil, fmt.Errorf("missing Section Header: %d %s", lineNo, line)
+				p.addParseError(token, ErrMissingSectionHeader, "", "")
+				p.addRawLine(curSect, token.raw)
+				isFatal = true
+				continue
+			}
+
+			lookupKey := curSect.safeKey(token.name)
+			if seenKeys[curSect] == nil {
+				seenKeys[curSect] = make(map[string]bool)
+			} else if seenKeys[curSect][lookupKey] {
+				p.addParseError(token, ErrDuplicateKey, curSect.Name, token.name)
 			}
-			curSect.Add(strings.TrimSpace(match[1]), match[3])
+
+			seenKeys[curSect][lookupKey] = true
+			pending = &pendingOption{
+				key:    token.name,
+				indent: token.indent,
+				lineNo: token.line,
+				column: token.column,
+				raw:    []string{token.raw},
+				values: []string{token.value},
+			}
+		default:
+			p.addParseError(token, ErrUnrecognizedLine, "", "")
+			p.addRawLine(curSect, token.raw)
 		}
 	}
+
+	if lexer.err != nil {
+		return nil, lexer.err
+	}
+
+	if pending != nil {
+		pending.finish(curSect, p.getInlineCommentPrefixes())
+	}
+
+	if len(p.parseErrors) != 0 && (isFatal || p.isStrictSyntax()) {
+		return nil, &SyntaxError{Errors: p.parseErrors}
+	}
+
 	return p, nil
 }
 
-func parseBytes(value []byte) (*ConfigParser, error) {
-	return parseString(string(value))
+// isCommentLine returns true if the trimmed line is a comment.
+func isCommentLine(line string) bool {
+	return strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") ||
+		strings.HasPrefix(line, "!")
 }
 
-func parseString(value string) (*ConfigParser, error) {
-	p := NewConfigParser()
-	allLines := strings.Split(value, "\n")
-	var lineNo int
-	var curSect *Section
+// getIndentation returns the count of the leading whitespaces of the line.
+func getIndentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
 
-	for _, current := range allLines {
+// parseOptionValue returns the final value of an option from the lines of
+// its value. a single double-quoted line is unquoted; otherwise the inline
+// comments are stripped and the lines are joined with newlines.
+func parseOptionValue(values []string, prefixes []string) (string, bool) {
+	if len(values) == 1 {
+		if value, ok := unquoteValue(values[0], prefixes); ok {
+			return value, true
+		}
+	}
 
-		lineNo++
-		if len(current) == 0 {
+	var lines []string
+	for _, current := range values {
+		current = strings.TrimSpace(stripInlineComment(current, prefixes))
+		if len(lines) != 0 && current == "" {
 			continue
 		}
 
-		line := strings.TrimSpace(current)
+		lines = append(lines, current)
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n")), false
+}
 
-		// Skip comment lines and empty lines
-		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") || line == "" {
+// stripInlineComment removes the comment at the end of the value, if it
+// starts with one of the prefixes preceded by a whitespace.
+func stripInlineComment(value string, prefixes []string) string {
+	index := -1
+	for _, prefix := range prefixes {
+		if prefix == "" {
 			continue
 		}
 
-		if match := sectionHeader.FindStringSubmatch(line); len(match) > 0 {
-			section := match[1]
-			if section == defaultSectionName {
-				curSect = p.defaults
-			} else if _, present := p.config[section]; !present {
-				curSect = newSection(section)
-				p.config[section] = curSect
+		for i := 1; i < len(value); i++ {
+			if (value[i-1] == ' ' || value[i-1] == '\t') && strings.HasPrefix(value[i:], prefix) {
+				if index == -1 || i < index {
+					index = i
+				}
+
+				break
 			}
-		} else if match = keyValue.FindStringSubmatch(line); len(match) > 0 {
-			if curSect == nil {
-				return nil, fmt.Errorf("missing Section Header: %d %s", lineNo, line)
+		}
+	}
+
+	if index == -1 {
+		return value
+	}
+
+	return value[:index]
+}
+
+// unquoteValue unquotes the double-quoted value, replacing its escape
+// sequences; ok is false if the value isn't double-quoted, or if there is
+// anything other than a comment after the closing quote.
+func unquoteValue(value string, prefixes []string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, `"`) {
+		return "", false
+	}
+
+	result := new(strings.Builder)
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			rest := strings.TrimSpace(value[i+1:])
+			if rest != "" && strings.TrimSpace(stripInlineComment(" "+rest, prefixes)) != "" {
+				return "", false
+			}
+
+			return result.String(), true
+		case '\\':
+			if i+1 == len(value) {
+				return "", false
 			}
 
-			curSect.Add(strings.TrimSpace(match[1]), match[3])
+			i++
+			switch value[i] {
+			case 'n':
+				result.WriteByte('\n')
+			case 't':
+				result.WriteByte('\t')
+			case 'r':
+				result.WriteByte('\r')
+			case '"', '\\':
+				result.WriteByte(value[i])
+			default:
+				// keep the unknown escape sequences as they are.
+				result.WriteByte('\\')
+				result.WriteByte(value[i])
+			}
+		default:
+			result.WriteByte(value[i])
 		}
 	}
 
-	return p, nil
+	// the closing quote is missing.
+	return "", false
+}
+
+// quoteValue double-quotes the value, escaping the characters which can't be
+// written as they are.
+func quoteValue(value string) string {
+	return `"` + quoteReplacer.Replace(value) + `"`
+}
+
+// formatOptionValue returns the text which is written for the value of an
+// option; the value is quoted if it was quoted before, or if it can't be
+// parsed back otherwise. the values with multiple lines are written as
+// continuation lines if possible.
+func formatOptionValue(value string, quoted bool) string {
+	if quoted || value != strings.TrimSpace(value) || strings.HasPrefix(value, `"`) ||
+		strings.ContainsAny(value, "\r\t") || strings.Contains(value, " #") ||
+		strings.Contains(value, " ;") {
+		return quoteValue(value)
+	}
+
+	if !strings.Contains(value, "\n") {
+		return value
+	}
+
+	lines := strings.Split(value, "\n")
+	for _, current := range lines[1:] {
+		if current == "" || current != strings.TrimSpace(current) {
+			return quoteValue(value)
+		}
+	}
+
+	return strings.Join(lines, "\n"+continuationIndent)
 }
 
 func parseToStringArray(value string) []string {
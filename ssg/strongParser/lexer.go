@@ -0,0 +1,76 @@
+package strongParser
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+func newINILexer(r io.Reader) *iniLexer {
+	return &iniLexer{reader: bufio.NewReader(r)}
+}
+
+// next returns the token of the next line; ok is false at the end of the
+// text (or if the text can't be read, see err).
+func (l *iniLexer) next() (token *iniToken, ok bool) {
+	if l.err != nil {
+		return nil, false
+	}
+
+	raw, err := l.reader.ReadString('\n')
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			l.err = err
+			return nil, false
+		} else if raw == "" {
+			// the last line ends with a newline.
+			return nil, false
+		}
+	}
+
+	l.lineNo++
+	raw = strings.TrimSuffix(raw, "\n")
+	line := strings.TrimSpace(raw)
+	indent := getIndentation(raw)
+	token = &iniToken{
+		raw:    raw,
+		line:   l.lineNo,
+		column: utf8.RuneCountInString(raw[:indent]) + 1,
+		indent: indent,
+	}
+
+	switch {
+	case line == "":
+		token.kind = blankToken
+	case isCommentLine(line):
+		token.kind = commentToken
+	case strings.HasPrefix(line, "["):
+		end := strings.Index(line, "]")
+		rest := ""
+		if end != -1 {
+			rest = strings.TrimSpace(line[end+1:])
+		}
+
+		if end <= 1 || (rest != "" && !isCommentLine(rest)) {
+			token.kind = invalidToken
+			break
+		}
+
+		token.kind = sectionToken
+		token.name = line[1:end]
+	default:
+		match := keyValue.FindStringSubmatch(line)
+		if len(match) == 0 {
+			token.kind = invalidToken
+			break
+		}
+
+		token.kind = optionToken
+		token.name = strings.TrimSpace(match[1])
+		token.value = match[3]
+	}
+
+	return token, true
+}
@@ -3,11 +3,19 @@ package strongParser
 import (
 	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/AnimeKaizoku/ssg/ssg/internal"
 )
 
 func (p *ConfigParser) isDefaultSection(section string) bool {
@@ -65,12 +73,33 @@ func (p *ConfigParser) Options(section string) ([]string, error) {
 	return options, nil
 }
 
-// Get returns string value for the named option.
+// Get returns string value for the named option, interpolated using the
+// interpolation mode of the options.
 //
 // Returns an error if a section does not exist
 // Returns an error if the option does not exist either in the section or in
 // the defaults
+// Returns an error if the value can't be interpolated
 func (p *ConfigParser) Get(section, option string) (string, error) {
+	value, err := p.GetRaw(section, option)
+	if err != nil {
+		return "", err
+	}
+
+	value, err = p.interpolate(section, option, value)
+	if err != nil || !p.isResolvingSecrets() {
+		return value, err
+	}
+
+	return p.ResolveSecret(value)
+}
+
+// GetRaw returns string value for the named option, without interpolating it.
+//
+// Returns an error if a section does not exist
+// Returns an error if the option does not exist either in the section or in
+// the defaults
+func (p *ConfigParser) GetRaw(section, option string) (string, error) {
 	if section == "" || option == "" {
 		return "", errors.New("section and option must be non-empty")
 	}
@@ -93,6 +122,591 @@ func (p *ConfigParser) Get(section, option string) (string, error) {
 	return "", getNoOptionError(section, option)
 }
 
+// isStrict returns true if the options enable the strict mode.
+func (p *ConfigParser) isStrict() bool {
+	return p.options != nil && p.options.Strict
+}
+
+// GetOrigin returns the layer which supplied the value of the option; it's
+// nil if the value has been set by Set.
+//
+// Returns an error if a section does not exist
+// Returns an error if the option does not exist either in the section or in
+// the defaults
+func (p *ConfigParser) GetOrigin(section, option string) (*ValueOrigin, error) {
+	if _, err := p.GetRaw(section, option); err != nil {
+		return nil, err
+	}
+
+	if s := p.config[section]; s != nil {
+		if _, err := s.Get(option); err == nil {
+			return s.origins[s.safeKey(option)], nil
+		}
+	}
+
+	return p.defaults.origins[p.defaults.safeKey(option)], nil
+}
+
+// setOriginsFile sets the name of the file of the options (and of the parse
+// errors) which have been parsed from the text of the file.
+func (p *ConfigParser) setOriginsFile(filename string) {
+	for _, s := range p.getAllSections() {
+		for _, origin := range s.origins {
+			if origin.Source == SourceFile && origin.Name == "" {
+				origin.Name = filename
+			}
+		}
+	}
+
+	for _, parseErr := range p.parseErrors {
+		if parseErr.File == "" {
+			parseErr.File = filename
+		}
+	}
+}
+
+// ParseErrors returns the problems of the parsed INI text (and of its other
+// layers), such as the duplicate keys; see the StrictSyntax option.
+func (p *ConfigParser) ParseErrors() []*ParseError {
+	return p.parseErrors
+}
+
+// addParseError adds the problem of the line to the parse errors.
+func (p *ConfigParser) addParseError(token *iniToken, err error, section, key string) {
+	p.parseErrors = append(p.parseErrors, &ParseError{
+		Line:    token.line,
+		Column:  token.column,
+		Section: section,
+		Key:     key,
+		Text:    strings.TrimSpace(token.raw),
+		Err:     err,
+	})
+}
+
+// isStrictSyntax returns true if the options enable the strict syntax mode.
+func (p *ConfigParser) isStrictSyntax() bool {
+	return p.options != nil && p.options.StrictSyntax
+}
+
+// getAllSections returns the DEFAULT section and the other sections, in the
+// order they were parsed or added.
+func (p *ConfigParser) getAllSections() []*Section {
+	sections := []*Section{p.defaults}
+	for _, name := range p.order {
+		if current, present := p.config[name]; present {
+			sections = append(sections, current)
+		}
+	}
+
+	return sections
+}
+
+// merge sets the options of the other parser, which is a higher layer, in
+// this parser; their origins are kept.
+func (p *ConfigParser) merge(other *ConfigParser) {
+	p.files = append(p.files, other.files...)
+	p.parseErrors = append(p.parseErrors, other.parseErrors...)
+	for key, value := range other.envValues {
+		if p.envValues == nil {
+			p.envValues = make(map[string]string)
+		}
+
+		p.envValues[key] = value
+	}
+
+	for _, s := range other.getAllSections() {
+		items := s.Items()
+		if s == other.defaults && len(items) == 0 {
+			continue
+		}
+
+		target := p.getOrAddLayerSection(s.Name)
+		for key, value := range items {
+			target.setLayer(key, value, s.origins[s.safeKey(key)])
+		}
+	}
+}
+
+// lookupEnv returns the value of the environment variable, or of the
+// variable of the parsed .env files if it's not set; found is false if
+// neither of them is set.
+func (p *ConfigParser) lookupEnv(name string) (value string, found bool) {
+	if value, found = os.LookupEnv(name); found {
+		return value, true
+	}
+
+	value, found = p.envValues[name]
+	return value, found
+}
+
+// getEnvKey returns the name of the environment variable of the option,
+// such as "SECTION_KEY", prefixed by the EnvPrefix option (if any).
+func (p *ConfigParser) getEnvKey(section, key string) string {
+	name := getEnvOverrideKey(section, key)
+	if section == "" {
+		name = strings.TrimPrefix(name, "_")
+	}
+
+	if p.options != nil && p.options.EnvPrefix != "" {
+		name = strings.TrimSuffix(p.options.EnvPrefix, "_") + "_" + name
+	}
+
+	return name
+}
+
+// getValueSources returns the sources of the values of the fields, other
+// than their `default` tag, in the order they are tried.
+func (p *ConfigParser) getValueSources() []ValueSource {
+	if p.options != nil && p.options.PreferEnv {
+		return []ValueSource{SourceEnv, SourceFile}
+	}
+
+	return []ValueSource{SourceFile, SourceEnv}
+}
+
+// getFieldRawValue returns the value of the field from the given source (the
+// config or the environment variables), resolved if it's a secret; found is
+// false if the source doesn't have the value. if the secret can't be
+// resolved, its reference is returned with the error.
+func (p *ConfigParser) getFieldRawValue(
+	fByName reflect.StructField,
+	source ValueSource,
+	section, key string,
+) (value string, found bool, err error) {
+	if source == SourceFile {
+		value, err = p.GetRaw(section, key)
+		if err == nil {
+			value, err = p.interpolate(section, key, value)
+		}
+
+		if err != nil {
+			// the option doesn't exist (or it can't be interpolated).
+			return "", false, nil
+		}
+
+		value, err = p.resolveFieldSecret(fByName, value)
+		if err != nil {
+			value, _ = p.GetRaw(section, key)
+			return value, true, err
+		}
+
+		return value, true, nil
+	}
+
+	for _, envTry := range getEnvTries(fByName, section, key, p) {
+		envValue, found := p.lookupEnv(envTry)
+		if !found {
+			continue
+		}
+
+		value, err = p.resolveFieldSecret(fByName, envValue)
+		if err != nil {
+			return envValue, true, err
+		}
+
+		return value, true, nil
+	}
+
+	return "", false, nil
+}
+
+// applyEnvOverrides overrides the options by the environment variables
+// named "SECTION_KEY" (prefixed by the EnvPrefix option, if any).
+func (p *ConfigParser) applyEnvOverrides() {
+	for _, s := range p.getAllSections() {
+		for _, key := range s.Options() {
+			envKey := p.getEnvKey(s.Name, key)
+			value, found := p.lookupEnv(envKey)
+			if !found {
+				continue
+			}
+
+			s.setLayer(key, value, &ValueOrigin{Source: SourceEnv, Name: envKey})
+		}
+	}
+}
+
+// applyFlags overrides the options by the "--section.key=value" flags of the
+// arguments; the arguments after "--" are ignored.
+func (p *ConfigParser) applyFlags(args []string) {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+
+		name, value, found := parseFlag(arg)
+		if !found {
+			continue
+		}
+
+		section, key := DefaultMainSection, name
+		if p.options.MainSectionName != "" {
+			section = p.options.MainSectionName
+		}
+
+		if index := strings.LastIndex(name, "."); index != -1 {
+			section, key = name[:index], name[index+1:]
+		}
+
+		if section == "" || key == "" {
+			continue
+		}
+
+		p.getOrAddLayerSection(section).setLayer(key, value, &ValueOrigin{Source: SourceFlag, Name: arg})
+	}
+}
+
+// applyLayers applies the layers of the options to the parsed files: the
+// drop-in files, the environment variables and the flags, in order; baseDir
+// is the directory of the main file, the drop-in files are ignored if it's
+// empty.
+func (p *ConfigParser) applyLayers(baseDir string) error {
+	if p.options == nil {
+		return nil
+	}
+
+	if p.options.DropInDir != "" && baseDir != "" {
+		dir := p.options.DropInDir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(baseDir, dir)
+		}
+
+		files, err := filepath.Glob(filepath.Join(dir, dropInPattern))
+		if err != nil {
+			return err
+		}
+
+		// the directory changes when a drop-in file is added or removed.
+		p.files = append(p.files, dir)
+
+		for _, current := range files {
+			dropIn, err := parseFileLayer(current, p.options, nil, 0)
+			if err != nil {
+				return err
+			}
+
+			p.merge(dropIn)
+		}
+	}
+
+	if p.options.EnvOverrides {
+		p.applyEnvOverrides()
+	}
+
+	if p.options.ReadFlags {
+		args := p.options.Args
+		if args == nil {
+			args = os.Args[1:]
+		}
+
+		p.applyFlags(args)
+	}
+
+	return nil
+}
+
+// loadIncludes merges the files of the include directives into the parser;
+// baseDir is the directory of the file which contains them.
+func (p *ConfigParser) loadIncludes(baseDir string, visited map[string]bool, depth int) error {
+	for _, pattern := range p.includes {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(baseDir, pattern)
+		}
+
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		} else if len(files) == 0 && !isGlobPattern(pattern) {
+			return fmt.Errorf("included file not found: %s", pattern)
+		}
+
+		for _, current := range files {
+			included, err := parseFileLayer(current, p.options, visited, depth+1)
+			if err != nil {
+				return err
+			}
+
+			p.merge(included)
+		}
+	}
+
+	return nil
+}
+
+// addFieldError adds an invalid field to the field errors of the parser.
+func (p *ConfigParser) addFieldError(
+	myType reflect.Type,
+	fByName reflect.StructField,
+	section, key string,
+	source ValueSource,
+	value string,
+	err error,
+) {
+	if isSecretField(fByName) {
+		value = maskSecret(value)
+	}
+
+	fieldError := &FieldError{
+		Field:   myType.Name() + "." + fByName.Name,
+		Section: section,
+		Key:     key,
+		Source:  source,
+		Value:   value,
+		Err:     err,
+	}
+
+	if source == SourceFile {
+		// the value may come from the other layers of the parser.
+		origin, _ := p.GetOrigin(section, key)
+		if origin != nil {
+			fieldError.Source = origin.Source
+			fieldError.Line = origin.Line
+			fieldError.Column = origin.Column
+			if origin.Source == SourceFile {
+				fieldError.File = origin.Name
+			}
+		}
+	}
+
+	p.fieldErrors = append(p.fieldErrors, fieldError)
+}
+
+// addConversionError adds the value which can't be converted to the type of
+// its field to the field errors, if the strict mode is enabled; the empty
+// values are ignored.
+func (p *ConfigParser) addConversionError(
+	myType reflect.Type,
+	fByName reflect.StructField,
+	section, key string,
+	source ValueSource,
+	value string,
+	err error,
+) {
+	if !p.isStrict() || strings.TrimSpace(value) == "" {
+		return
+	}
+
+	p.addFieldError(myType, fByName, section, key, source, value, err)
+}
+
+// validateField checks the value of the field against its validation tags
+// (`required`, `min`, `max`, `oneof` and `regex`) and adds the failures to
+// the field errors. the tags other than `required` are only checked for the
+// non-zero values, and the fields which already have an error (such as a
+// value which can't be converted) aren't checked at all.
+func (p *ConfigParser) validateField(
+	myType reflect.Type,
+	fByName reflect.StructField,
+	field rValue,
+	section string,
+) {
+	tag := fByName.Tag
+	required := BoolMapping[strings.ToLower(tag.Get("required"))]
+	minTag, maxTag := tag.Get("min"), tag.Get("max")
+	oneOfTag, regexTag := tag.Get("oneof"), tag.Get("regex")
+	if !required && minTag == "" && maxTag == "" && oneOfTag == "" && regexTag == "" {
+		return
+	}
+
+	section, key := getFieldLocation(fByName, section, p)
+	if p.hasFieldError(myType, fByName, section) {
+		return
+	}
+
+	addError := func(err error) {
+		value, _ := formatFieldValue(field, strings.ToLower(tag.Get("type")))
+		source := getFieldSource(fByName, section, key, p)
+		p.addFieldError(myType, fByName, section, key, source, value, err)
+	}
+
+	if field.IsZero() {
+		if required {
+			addError(errors.New("value is required"))
+		}
+
+		return
+	}
+
+	if field.Kind() == reflect.Ptr {
+		field = field.Elem()
+	}
+
+	for _, current := range []struct {
+		tag   string
+		isMin bool
+	}{{minTag, true}, {maxTag, false}} {
+		if current.tag == "" {
+			continue
+		}
+
+		if err := checkFieldLimit(field, current.tag, current.isMin); err != nil {
+			addError(err)
+		}
+	}
+
+	if oneOfTag != "" {
+		value, _ := formatFieldValue(field, strings.ToLower(tag.Get("type")))
+		if !slices.Contains(strings.Fields(oneOfTag), value) {
+			addError(fmt.Errorf("value must be one of: %s", oneOfTag))
+		}
+	}
+
+	if regexTag != "" && field.Kind() == reflect.String {
+		matched, err := regexp.MatchString(regexTag, field.String())
+		if err != nil {
+			addError(fmt.Errorf("invalid regex tag: %w", err))
+		} else if !matched {
+			addError(fmt.Errorf("value must match: %s", regexTag))
+		}
+	}
+}
+
+// hasFieldError returns true if the field of the section already has an
+// error.
+func (p *ConfigParser) hasFieldError(myType reflect.Type, fByName reflect.StructField, section string) bool {
+	name := myType.Name() + "." + fByName.Name
+	for _, current := range p.fieldErrors {
+		if current.Field == name && current.Section == section {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getConfigError returns the field errors of the parser as a *ConfigError,
+// or nil if there is no field error.
+func (p *ConfigParser) getConfigError() error {
+	if len(p.fieldErrors) == 0 {
+		return nil
+	}
+
+	return &ConfigError{Errors: p.fieldErrors}
+}
+
+// ResolveSecret resolves the value if it's a secret reference, such as
+// "file:///run/secrets/token"; the other values are returned as they are.
+// the returned errors wrap ErrSecretResolution.
+func (p *ConfigParser) ResolveSecret(value string) (string, error) {
+	return resolveSecret(value, p.options)
+}
+
+// resolveFieldSecret resolves the value of the field, if it's a secret field
+// or the ResolveSecrets option is true; the references of the secret fields
+// are recorded, so they're written back by MarshalConfig.
+func (p *ConfigParser) resolveFieldSecret(fByName reflect.StructField, value string) (string, error) {
+	isSecret := isSecretField(fByName)
+	if !isSecret && !p.isResolvingSecrets() {
+		return value, nil
+	}
+
+	resolved, err := p.ResolveSecret(value)
+	if err == nil && isSecret && resolved != value {
+		setSecretReference(resolved, value)
+	}
+
+	return resolved, err
+}
+
+func (p *ConfigParser) isResolvingSecrets() bool {
+	return p.options != nil && p.options.ResolveSecrets
+}
+
+// getInterpolation returns the interpolation mode of the options.
+func (p *ConfigParser) getInterpolation() InterpolationMode {
+	if p.options == nil {
+		return NoInterpolation
+	}
+
+	return p.options.Interpolation
+}
+
+// interpolate returns the interpolated value of the option.
+func (p *ConfigParser) interpolate(section, option, value string) (string, error) {
+	mode := p.getInterpolation()
+	if mode == NoInterpolation {
+		return value, nil
+	}
+
+	return p.interpolateValue(mode, section, value, []string{getInterpolationKey(section, option)})
+}
+
+// interpolateValue replaces the references in the value; stack contains the
+// options which are being interpolated, so the cycles can be detected.
+func (p *ConfigParser) interpolateValue(mode InterpolationMode, section, value string, stack []string) (string, error) {
+	if len(stack) > maxInterpolationDepth {
+		return "", fmt.Errorf("%w: %s", ErrInterpolationDepth, stack[0])
+	}
+
+	marker, opening, closing := byte('%'), "(", ")s"
+	if mode == ExtendedInterpolation {
+		marker, opening, closing = '$', "{", "}"
+	}
+
+	result := new(strings.Builder)
+	for i := 0; i < len(value); i++ {
+		if value[i] != marker {
+			result.WriteByte(value[i])
+			continue
+		}
+
+		rest := value[i+1:]
+		if strings.HasPrefix(rest, string(marker)) {
+			// the escaped marker, such as "%%" or "$$".
+			result.WriteByte(marker)
+			i++
+			continue
+		}
+
+		end := strings.Index(rest, closing)
+		if !strings.HasPrefix(rest, opening) || end == -1 {
+			return "", fmt.Errorf("bad interpolation syntax in section '%s': '%s'", section, value)
+		}
+
+		reference := rest[len(opening):end]
+		refSection, refOption := section, reference
+		if mode == ExtendedInterpolation {
+			if before, after, found := strings.Cut(reference, ":"); found {
+				refSection, refOption = before, after
+			}
+		}
+
+		resolved, err := p.resolveReference(mode, refSection, refOption, stack)
+		if err != nil {
+			return "", err
+		}
+
+		result.WriteString(resolved)
+		i += end + len(closing)
+	}
+
+	return result.String(), nil
+}
+
+// resolveReference returns the interpolated value of the referenced option.
+func (p *ConfigParser) resolveReference(mode InterpolationMode, section, option string, stack []string) (string, error) {
+	if mode == ExtendedInterpolation && section == envSectionName {
+		value, present := p.lookupEnv(option)
+		if !present {
+			return "", fmt.Errorf("bad interpolation reference: no environment variable '%s'", option)
+		}
+
+		return value, nil
+	}
+
+	key := getInterpolationKey(section, option)
+	if slices.Contains(stack, key) {
+		return "", fmt.Errorf("%w: %s", ErrInterpolationCycle, strings.Join(append(stack, key), " -> "))
+	}
+
+	value, err := p.GetRaw(section, option)
+	if err != nil {
+		return "", fmt.Errorf("bad interpolation reference: %w", err)
+	}
+
+	return p.interpolateValue(mode, section, value, append(slices.Clip(stack), key))
+}
+
 func (p *ConfigParser) GetIntByType(section, option, fType string) (int64, error) {
 	result, err := p.Get(section, option)
 	if err != nil {
@@ -147,6 +761,117 @@ func (p *ConfigParser) Items(section string) (Dict, error) {
 	return p.config[section].Items(), nil
 }
 
+// AddSection adds a new empty section to the configuration.
+//
+// Returns an error if the section already exists, or if it's the DEFAULT
+// section.
+func (p *ConfigParser) AddSection(section string) error {
+	if section == "" {
+		return errors.New("section must be non-empty")
+	} else if p.isDefaultSection(section) || p.HasSection(section) {
+		return fmt.Errorf("section already exists: '%s'", section)
+	}
+
+	p.getOrAddSection(section)
+	return nil
+}
+
+// getOrAddSection returns the named section, adding it if it doesn't exist.
+func (p *ConfigParser) getOrAddSection(section string) *Section {
+	if p.isDefaultSection(section) {
+		if !slices.Contains(p.order, section) {
+			p.order = append(p.order, section)
+		}
+
+		return p.defaults
+	}
+
+	s, present := p.config[section]
+	if !present {
+		s = newSection(section)
+		p.config[section] = s
+		p.order = append(p.order, section)
+	}
+
+	return s
+}
+
+// getOrAddLayerSection returns the named section, adding it if it doesn't
+// exist; the added sections are not written back by WriteTo unless their
+// options are changed.
+func (p *ConfigParser) getOrAddLayerSection(section string) *Section {
+	if p.isDefaultSection(section) {
+		return p.defaults
+	}
+
+	s, present := p.config[section]
+	if !present {
+		s = p.getOrAddSection(section)
+		s.layered = true
+	}
+
+	return s
+}
+
+// addRawLine adds a line which is not an option (such as a comment) to the
+// section; the lines before the first section are kept in the preamble.
+func (p *ConfigParser) addRawLine(section *Section, raw string) {
+	if section == nil {
+		p.preamble = append(p.preamble, raw)
+		return
+	}
+
+	section.lines = append(section.lines, &sectionLine{raw: raw})
+}
+
+// getInlineCommentPrefixes returns the prefixes of the inline comments which
+// are stripped from the values.
+func (p *ConfigParser) getInlineCommentPrefixes() []string {
+	if p.options == nil {
+		return nil
+	}
+
+	return p.options.InlineCommentPrefixes
+}
+
+// WriteTo writes the configuration in the INI format to w.
+// the sections are written in the order they were parsed or added (the
+// DEFAULT section goes first if it was never seen in the parsed text), and
+// the comments, blank lines and the unchanged options are written exactly
+// as they were parsed. the values of the other layers (the included and
+// drop-in files, the environment variables and the flags) are not written.
+func (p *ConfigParser) WriteTo(w io.Writer) (int64, error) {
+	writer := &countingWriter{w: w}
+	for _, line := range p.preamble {
+		writer.WriteString(line + "\n")
+	}
+
+	if len(p.defaults.options) != 0 && !slices.Contains(p.order, defaultSectionName) {
+		p.defaults.writeTo(writer)
+	}
+
+	for _, section := range p.order {
+		if p.isDefaultSection(section) {
+			p.defaults.writeTo(writer)
+		} else if current, present := p.config[section]; present &&
+			(!current.layered || len(current.lines) != 0) {
+			current.writeTo(writer)
+		}
+	}
+
+	return writer.n, writer.err
+}
+
+// SaveFile writes the configuration to the given file path. the file is
+// written to a temporary file in the same directory first, which is then
+// renamed to the path, so it's never left half-written.
+func (p *ConfigParser) SaveFile(path string) error {
+	return internal.WriteFileAtomic(path, 0644, func(w io.Writer) error {
+		_, err := p.WriteTo(w)
+		return err
+	})
+}
+
 // Set puts the given option into the named section.
 //
 // Returns an error if the section does not exist.
@@ -258,31 +983,56 @@ func (p *ConfigParser) GetStringSlice(section, option string) ([]string, error)
 	return parseToStringArray(result), nil
 }
 
+// getArrayRawValue returns the value of an array option and its source; the
+// value is read from the config, then from the environment variables (or the
+// other way around, if the PreferEnv option is true).
+func (p *ConfigParser) getArrayRawValue(section, key, envKey string) (string, ValueSource) {
+	for _, source := range p.getValueSources() {
+		if source == SourceFile {
+			result, err := p.Get(section, key)
+			if err == nil {
+				return result, SourceFile
+			}
+
+			continue
+		}
+
+		for _, envTry := range p.getArrayEnvTries(section, key, envKey) {
+			if result, found := p.lookupEnv(envTry); found {
+				return result, SourceEnv
+			}
+		}
+	}
+
+	return "", ""
+}
+
+// getArrayEnvTries returns the names of the environment variables which the
+// value of an array option is read from, in order; envKey is the `env` tag
+// of the field.
+func (p *ConfigParser) getArrayEnvTries(section, key, envKey string) []string {
+	var envTries []string
+	if envKey != "" {
+		envTries = append(envTries, envKey)
+	}
+
+	if p.options != nil && p.options.EnvPrefix != "" {
+		return append(envTries, p.getEnvKey(section, key))
+	}
+
+	envTries = append(envTries, strings.ToUpper(section)+"_"+strings.ToUpper(key))
+	envTries = append(envTries, key)
+	envTries = append(envTries, strings.ToUpper(key))
+
+	return envTries
+}
+
 // getArrayValueToSet returns array value to set.
 // s is section; o is option; k is kind.
 func (p *ConfigParser) getArrayValueToSet(
 	section, key, envKey string,
 	k reflect.Kind, isRune bool) (rValue, error) {
-	result, err := p.Get(section, key)
-	if err != nil || result == "" {
-		// second try: read from environment variable
-		var envTries []string
-
-		if envKey != "" {
-			envTries = append(envTries, envKey)
-		}
-		envTries = append(envTries, strings.ToUpper(section)+"_"+strings.ToUpper(key))
-		envTries = append(envTries, key)
-		envTries = append(envTries, strings.ToUpper(key))
-
-		for _, envTry := range envTries {
-			result = os.Getenv(envTry)
-			if result != "" {
-				break
-			}
-		}
-	}
-
+	result, _ := p.getArrayRawValue(section, key, envKey)
 	if result == "" {
 		// TODO: Add support for default values in arrays
 		return invalidReflectValue, errors.New("getArrayValueToSet: no value found")
@@ -326,6 +1076,73 @@ func (p *ConfigParser) getArrayValueToSet(
 	return invalidReflectValue, fmt.Errorf("unsupported kind: %s", k.String())
 }
 
+// getMapValueToSet returns a map of the type of the field, containing the
+// items of the section. the items which can't be converted to the value type
+// of the map are left out, and reported as field errors in the strict mode.
+func (p *ConfigParser) getMapValueToSet(
+	myType reflect.Type,
+	fByName reflect.StructField,
+	section string,
+) (rValue, error) {
+	t := fByName.Type
+	items, err := p.Items(section)
+	if err != nil {
+		return invalidReflectValue, err
+	}
+
+	result := reflect.MakeMapWithSize(t, len(items))
+	for key := range items {
+		// the value is interpolated (and resolved, if it's a secret).
+		value, err := p.Get(section, key)
+		if err != nil {
+			return invalidReflectValue, err
+		}
+
+		elem := reflect.New(t.Elem()).Elem()
+		if err = setValueFromString(elem, "", value); err != nil {
+			p.addConversionError(myType, fByName, section, key, SourceFile, value, err)
+			continue
+		}
+
+		result.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+	}
+
+	return result, nil
+}
+
+// getSectionsWithPrefix returns the names of the sections starting with the
+// prefix, sorted by the rest of their names: the numeric ones come first (in
+// numeric order), then the others (in lexical order).
+func (p *ConfigParser) getSectionsWithPrefix(prefix string) []string {
+	var sections []string
+	for section := range p.config {
+		if strings.HasPrefix(section, prefix) && len(section) > len(prefix) {
+			sections = append(sections, section)
+		}
+	}
+
+	sort.Slice(sections, func(i, j int) bool {
+		first := sections[i][len(prefix):]
+		second := sections[j][len(prefix):]
+		firstNum, err1 := strconv.ParseInt(first, 10, 64)
+		secondNum, err2 := strconv.ParseInt(second, 10, 64)
+		switch {
+		case err1 == nil && err2 == nil:
+			if firstNum != secondNum {
+				return firstNum < secondNum
+			}
+		case err1 == nil:
+			return true
+		case err2 == nil:
+			return false
+		}
+
+		return first < second
+	})
+
+	return sections
+}
+
 func (p *ConfigParser) GetIntSlice(section, option string) ([]int64, error) {
 	result, err := p.Get(section, option)
 	if err != nil {
@@ -363,33 +1180,150 @@ func (p *ConfigParser) RemoveOption(section, option string) error {
 
 //---------------------------------------------------------
 
+// add adds a continuation line to the value of the option.
+func (o *pendingOption) add(raw, value string) {
+	o.raw = append(o.raw, raw)
+	o.values = append(o.values, value)
+}
+
+// finish adds the parsed option to the section.
+func (o *pendingOption) finish(s *Section, prefixes []string) {
+	value, quoted := parseOptionValue(o.values, prefixes)
+	line := s.add(o.key, value, strings.Join(o.raw, "\n"))
+	line.lineNo = o.lineNo
+	line.quoted = quoted
+	s.origins[s.safeKey(o.key)] = &ValueOrigin{Source: SourceFile, Line: o.lineNo, Column: o.column}
+}
+
+//---------------------------------------------------------
+
+// Add sets the value of the option, adding it to the section if it doesn't
+// exist. new options are placed after the last option of the section.
 func (s *Section) Add(key, value string) error {
+	s.add(key, s.safeValue(value), "")
+	return nil
+}
+
+// add sets the value of the option and returns its line; raw is the
+// original text of the line when the option is being parsed, otherwise empty.
+func (s *Section) add(key, value, raw string) *sectionLine {
 	lookupKey := s.safeKey(key)
+	delete(s.origins, lookupKey)
+	if original, present := s.lookup[lookupKey]; present {
+		// keep the original spelling of the key.
+		key = original
+		delete(s.layers, key)
+		if raw == "" {
+			line := s.getLine(lookupKey)
+			if line != nil {
+				if s.options[key] != value {
+					line.raw = ""
+				}
+
+				s.options[key] = value
+				return line
+			}
+		}
+	}
 
-	s.options[key] = s.safeValue(value)
+	s.options[key] = value
 	s.lookup[lookupKey] = key
+
+	line := &sectionLine{key: key, raw: raw}
+	if raw != "" {
+		s.lines = append(s.lines, line)
+		return line
+	}
+
+	s.lines = slices.Insert(s.lines, s.getInsertIndex(), line)
+	return line
+}
+
+// setLayer sets the value of the option from the other layers, which
+// overrides the value of the section without changing its lines.
+func (s *Section) setLayer(key, value string, origin *ValueOrigin) {
+	lookupKey := s.safeKey(key)
+	if original, present := s.lookup[lookupKey]; present {
+		key = original
+	} else {
+		s.lookup[lookupKey] = key
+	}
+
+	if s.layers == nil {
+		s.layers = make(Dict)
+	}
+
+	s.layers[key] = value
+	if origin != nil {
+		s.origins[lookupKey] = origin
+	} else {
+		delete(s.origins, lookupKey)
+	}
+}
+
+// getLine returns the last line of the option with the given lookup key.
+func (s *Section) getLine(lookupKey string) *sectionLine {
+	for i := len(s.lines) - 1; i >= 0; i-- {
+		if s.lines[i].key != "" && s.safeKey(s.lines[i].key) == lookupKey {
+			return s.lines[i]
+		}
+	}
+
 	return nil
 }
 
+// getInsertIndex returns the index of the lines which a new option should be
+// inserted at: after the last option, or if there is none, after the last
+// line which is not blank.
+func (s *Section) getInsertIndex() int {
+	index := 0
+	for i, line := range s.lines {
+		if line.key != "" {
+			index = i + 1
+		} else if index == i && strings.TrimSpace(line.raw) != "" {
+			index = i + 1
+		}
+	}
+
+	return index
+}
+
 func (s *Section) Get(key string) (string, error) {
 	lookupKey, present := s.lookup[s.safeKey(key)]
 	if !present {
 		return "", getNoOptionError(s.Name, key)
 	}
 
-	if value, present := s.options[lookupKey]; present {
-		return strings.TrimSpace(value), nil
+	if value, present := s.layers[lookupKey]; present {
+		return value, nil
+	} else if value, present := s.options[lookupKey]; present {
+		return value, nil
 	}
 
 	return "", getNoOptionError(s.Name, key)
 }
 
 func (s *Section) Options() []string {
-	return s.options.Keys()
+	return s.Items().Keys()
 }
 
+// Items returns the options of the section, including the values of the
+// other layers.
 func (s *Section) Items() Dict {
-	return s.options
+	if len(s.layers) == 0 {
+		return s.options
+	}
+
+	items := make(Dict, len(s.options)+len(s.layers))
+	for key, value := range s.options {
+		items[key] = value
+	}
+
+	for key, value := range s.layers {
+		items[key] = value
+	}
+
+	return items
 }
 
 func (s *Section) safeValue(in string) string {
@@ -401,23 +1335,51 @@ func (s *Section) safeKey(in string) string {
 }
 
 func (s *Section) Remove(key string) error {
-	_, present := s.options[key]
+	lookupKey := s.safeKey(key)
+	original, present := s.lookup[lookupKey]
 	if !present {
 		return getNoOptionError(s.Name, key)
 	}
 
-	// delete doesn't return anything, but this does require
-	// that the passed key to be removed matches the options key.
-	delete(s.lookup, s.safeKey(key))
-	delete(s.options, key)
+	delete(s.lookup, lookupKey)
+	delete(s.options, original)
+	delete(s.layers, original)
+	delete(s.origins, lookupKey)
+	s.lines = slices.DeleteFunc(s.lines, func(line *sectionLine) bool {
+		return line.key != "" && s.safeKey(line.key) == lookupKey
+	})
 	return nil
 }
 
+// writeTo writes the section and its lines in the INI format.
+func (s *Section) writeTo(w *countingWriter) {
+	if s.header != "" {
+		w.WriteString(s.header + "\n")
+	} else {
+		if w.n != 0 {
+			// separate the new sections from the previous ones.
+			w.WriteString("\n")
+		}
+
+		w.WriteString("[" + s.Name + "]\n")
+	}
+
+	for _, line := range s.lines {
+		if line.key == "" || line.raw != "" {
+			w.WriteString(line.raw + "\n")
+			continue
+		}
+
+		w.WriteString(line.key + " = " + formatOptionValue(s.options[line.key], line.quoted) + "\n")
+	}
+}
+
 func newSection(name string) *Section {
 	return &Section{
 		Name:    name,
 		options: make(Dict),
 		lookup:  make(Dict),
+		origins: make(map[string]*ValueOrigin),
 	}
 }
 
@@ -437,6 +1399,20 @@ func (d Dict) Keys() []string {
 
 //---------------------------------------------------------
 
+// WriteString writes the string to the underlying writer, unless a previous
+// write has failed.
+func (w *countingWriter) WriteString(value string) {
+	if w.err != nil {
+		return
+	}
+
+	n, err := io.WriteString(w.w, value)
+	w.n += int64(n)
+	w.err = err
+}
+
+//---------------------------------------------------------
+
 func NewChainMap(dicts ...Dict) *ChainMap {
 	chainMap := &ChainMap{
 		maps: make([]Dict, 0),
@@ -464,6 +1440,263 @@ func (c *ChainMap) Get(key string) string {
 
 //---------------------------------------------------------
 
+func (f ConfigFormatFunc) Decode(data []byte, opt *ConfigParserOptions) (*ConfigParser, error) {
+	return f(data, opt)
+}
+
+//---------------------------------------------------------
+
+func (f SecretResolverFunc) Resolve(reference string, opt *ConfigParserOptions) (string, error) {
+	return f(reference, opt)
+}
+
+//---------------------------------------------------------
+
+// String returns SecretMask, so the secret isn't revealed when it's
+// printed; use Value to get the secret.
+func (s Secret) String() string {
+	return maskSecret(string(s))
+}
+
+func (s Secret) GoString() string {
+	return `"` + s.String() + `"`
+}
+
+// Value returns the secret itself.
+func (s Secret) Value() string {
+	return string(s)
+}
+
+//---------------------------------------------------------
+
+// Get returns the current config; it's safe to be called concurrently.
+func (w *ConfigWatcher[T]) Get() *T {
+	return w.current.Load()
+}
+
+// LastError returns the error of the last reload, or nil if it succeeded.
+func (w *ConfigWatcher[T]) LastError() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.lastErr
+}
+
+// Reload re-parses the config immediately, even if the files haven't
+// changed. if it fails, the old config is kept and the error is returned
+// (and passed to the OnError callback).
+func (w *ConfigWatcher[T]) Reload() error {
+	w.mutex.Lock()
+	notify, err := w.reload()
+	w.mutex.Unlock()
+
+	if notify != nil {
+		notify()
+	}
+
+	return err
+}
+
+// Stop stops watching the files; it waits for the running reload (if any)
+// to finish.
+func (w *ConfigWatcher[T]) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+
+	<-w.done
+}
+
+func (w *ConfigWatcher[T]) run() {
+	defer close(w.done)
+
+	interval := w.options.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		case <-w.options.Notify:
+		}
+
+		w.checkFiles()
+	}
+}
+
+// checkFiles reloads the config if any of the watched files has changed.
+func (w *ConfigWatcher[T]) checkFiles() {
+	var notify func()
+	w.mutex.Lock()
+	for path, stamp := range w.stamps {
+		if getFileStamp(path) != stamp {
+			notify, _ = w.reload()
+			break
+		}
+	}
+	w.mutex.Unlock()
+
+	if notify != nil {
+		notify()
+	}
+}
+
+// reload re-parses the config and swaps it; the mutex must be locked. the
+// returned function (if not nil) calls the callback of the result, and
+// should be called after unlocking the mutex.
+func (w *ConfigWatcher[T]) reload() (func(), error) {
+	p, err := ParseWithOptions(w.path, w.options.ParserOptions)
+	newValue := new(T)
+	if err == nil {
+		err = parseFinalConfig(newValue, "", p)
+	}
+
+	if err != nil {
+		// keep watching the same files, so the config is reloaded again
+		// once they are fixed.
+		w.updateStamps(slices.Collect(maps.Keys(w.stamps)))
+		w.lastErr = err
+		if onError := w.options.OnError; onError != nil {
+			return func() { onError(err) }, err
+		}
+
+		return nil, err
+	}
+
+	w.lastErr = nil
+	w.updateStamps(append([]string{w.path}, p.files...))
+	oldValue := w.current.Swap(newValue)
+
+	var changes []FieldChange
+	diffConfigValues("", reflect.ValueOf(oldValue).Elem(), reflect.ValueOf(newValue).Elem(), &changes)
+	if onChange := w.options.OnChange; len(changes) != 0 && onChange != nil {
+		return func() { onChange(oldValue, newValue, changes) }, nil
+	}
+
+	return nil, nil
+}
+
+// updateStamps sets the current states of the files as the states of the
+// watched files.
+func (w *ConfigWatcher[T]) updateStamps(files []string) {
+	w.stamps = make(map[string]fileStamp, len(files))
+	for _, path := range files {
+		w.stamps[path] = getFileStamp(path)
+	}
+}
+
+//---------------------------------------------------------
+
+func (e *FieldError) Error() string {
+	location := fmt.Sprintf("section '%s', key '%s'", e.Section, e.Key)
+	if e.Line != 0 && e.File != "" {
+		location += fmt.Sprintf(", line %d of %s", e.Line, e.File)
+	} else if e.Line != 0 {
+		location += fmt.Sprintf(", line %d", e.Line)
+	}
+
+	if e.Source != "" {
+		location += ", from " + string(e.Source)
+	}
+
+	if e.Value != "" {
+		return fmt.Sprintf("%s (%s): invalid value '%s': %v", e.Field, location, e.Value, e.Err)
+	}
+
+	return fmt.Sprintf("%s (%s): %v", e.Field, location, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+//---------------------------------------------------------
+
+func (e *ConfigError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, current := range e.Errors {
+		messages = append(messages, current.Error())
+	}
+
+	return fmt.Sprintf(
+		"strongParser: %d invalid field(s):\n\t%s",
+		len(e.Errors),
+		strings.Join(messages, "\n\t"),
+	)
+}
+
+func (e *ConfigError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, current := range e.Errors {
+		errs = append(errs, current)
+	}
+
+	return errs
+}
+
+//---------------------------------------------------------
+
+func (e *ParseError) Error() string {
+	location := fmt.Sprintf("line %d, column %d", e.Line, e.Column)
+	if e.File != "" {
+		location += " of " + e.File
+	}
+
+	switch {
+	case e.Key != "":
+		return fmt.Sprintf("%s: %v '%s' in section '%s'", location, e.Err, e.Key, e.Section)
+	case e.Section != "":
+		return fmt.Sprintf("%s: %v '%s'", location, e.Err, e.Section)
+	}
+
+	return fmt.Sprintf("%s: %v: %s", location, e.Err, e.Text)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+//---------------------------------------------------------
+
+func (e *SyntaxError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, current := range e.Errors {
+		messages = append(messages, current.Error())
+	}
+
+	return fmt.Sprintf(
+		"strongParser: %d syntax error(s):\n\t%s",
+		len(e.Errors),
+		strings.Join(messages, "\n\t"),
+	)
+}
+
+func (e *SyntaxError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, current := range e.Errors {
+		errs = append(errs, current)
+	}
+
+	return errs
+}
+
+// setFile sets the name of the file of the errors which don't have it.
+func (e *SyntaxError) setFile(filename string) {
+	for _, current := range e.Errors {
+		if current.File == "" {
+			current.File = filename
+		}
+	}
+}
+
+//---------------------------------------------------------
+
 func (e *InvalidParseError) Error() string {
 	if e.Type == nil {
 		return "strongParser: Parse(nil)"
@@ -0,0 +1,226 @@
+package strongParser
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// RegisterSecretResolver registers the resolver of the secret references
+// which start with the given prefix (such as "vault://"); the resolver is
+// given the reference without its prefix. the resolver of the longest
+// matching prefix is used.
+func RegisterSecretResolver(prefix string, resolver SecretResolver) {
+	secretResolversMutex.Lock()
+	secretResolvers[prefix] = resolver
+	secretResolversMutex.Unlock()
+}
+
+// UnregisterSecretResolver removes the resolver of the given prefix.
+func UnregisterSecretResolver(prefix string) {
+	secretResolversMutex.Lock()
+	delete(secretResolvers, prefix)
+	secretResolversMutex.Unlock()
+}
+
+// EncryptSecret encrypts the value with the AES-GCM key (of 16, 24 or 32
+// bytes) and returns it as an "enc:" secret reference, which is decrypted
+// by the parser when the same key is given by the SecretKeySource option.
+func EncryptSecret(key []byte, value string) (string, error) {
+	aead, err := newSecretCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(value), nil)
+	return EncryptedSecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// resolveSecret resolves the value if it's a secret reference; the other
+// values are returned as they are.
+func resolveSecret(value string, opt *ConfigParserOptions) (string, error) {
+	prefix, resolver := getSecretResolver(value)
+	if resolver == nil {
+		return value, nil
+	}
+
+	resolved, err := resolver.Resolve(strings.TrimPrefix(value, prefix), opt)
+	if err != nil {
+		// the reference itself isn't included, since it may be encrypted.
+		return "", fmt.Errorf("%w: %s reference: %w", ErrSecretResolution, prefix, err)
+	}
+
+	return resolved, nil
+}
+
+// getSecretResolver returns the resolver of the longest prefix of the value,
+// or nil if the value isn't a secret reference.
+func getSecretResolver(value string) (string, SecretResolver) {
+	secretResolversMutex.RLock()
+	defer secretResolversMutex.RUnlock()
+
+	var prefix string
+	var resolver SecretResolver
+	for current, currentResolver := range secretResolvers {
+		if len(current) > len(prefix) && strings.HasPrefix(value, current) {
+			prefix, resolver = current, currentResolver
+		}
+	}
+
+	return prefix, resolver
+}
+
+// isSecretField returns true if the value of the field has to be masked by
+// DumpConfig; that's the fields with the `secret:"true"` tag and the fields
+// of the Secret type (which are masked by fmt too).
+func isSecretField(fByName reflect.StructField) bool {
+	if BoolMapping[strings.ToLower(fByName.Tag.Get("secret"))] {
+		return true
+	}
+
+	fType := fByName.Type
+	for fType.Kind() == reflect.Ptr || fType.Kind() == reflect.Slice {
+		fType = fType.Elem()
+	}
+
+	return fType == secretType
+}
+
+// setSecretReference records the reference which the secret value has been
+// resolved from.
+func setSecretReference(value, reference string) {
+	secretReferencesMutex.Lock()
+	secretReferences[value] = reference
+	secretReferencesMutex.Unlock()
+}
+
+// getSecretReference returns the reference which the secret value has been
+// resolved from, or the value itself if it hasn't been resolved from any.
+func getSecretReference(value string) string {
+	secretReferencesMutex.RLock()
+	defer secretReferencesMutex.RUnlock()
+
+	if reference, present := secretReferences[value]; present {
+		return reference
+	}
+
+	return value
+}
+
+// formatSecretField returns the value of the secret field to be marshaled:
+// its mask if maskSecrets is true, otherwise its secret reference.
+func formatSecretField(value string, maskSecrets bool) string {
+	if maskSecrets {
+		return maskSecret(value)
+	}
+
+	return getSecretReference(value)
+}
+
+// maskSecret returns SecretMask, or an empty string if the value is empty.
+func maskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	return SecretMask
+}
+
+// resolveFileSecret returns the content of the file, without its trailing
+// newline; "file:///run/secrets/token" refers to "/run/secrets/token".
+func resolveFileSecret(path string, _ *ConfigParserOptions) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func resolveEnvSecret(name string, _ *ConfigParserOptions) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	return value, nil
+}
+
+// resolveEncryptedSecret decrypts the base64 value, which is the nonce
+// followed by the sealed secret, with the key of the options.
+func resolveEncryptedSecret(value string, opt *ConfigParserOptions) (string, error) {
+	key, err := getSecretKey(opt)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newSecretCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %w", err)
+	} else if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("invalid encrypted value: too short")
+	}
+
+	nonceSize := aead.NonceSize()
+	plain, err := aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("can't decrypt the value: %w", err)
+	}
+
+	return string(plain), nil
+}
+
+// getSecretKey returns the AES key of the SecretKeySource option, which is
+// a "file://" or an "env://" reference to the base64 encoding of the key.
+func getSecretKey(opt *ConfigParserOptions) ([]byte, error) {
+	if opt == nil || opt.SecretKeySource == "" {
+		return nil, fmt.Errorf("no SecretKeySource is configured")
+	}
+
+	var encodedKey string
+	var err error
+	source := opt.SecretKeySource
+	switch {
+	case strings.HasPrefix(source, FileSecretPrefix):
+		encodedKey, err = resolveFileSecret(strings.TrimPrefix(source, FileSecretPrefix), opt)
+	case strings.HasPrefix(source, EnvSecretPrefix):
+		encodedKey, err = resolveEnvSecret(strings.TrimPrefix(source, EnvSecretPrefix), opt)
+	default:
+		err = fmt.Errorf("the SecretKeySource must be a %s or %s reference", FileSecretPrefix, EnvSecretPrefix)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encodedKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret key: %w", err)
+	}
+
+	return key, nil
+}
+
+func newSecretCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -1,6 +1,13 @@
 package strongParser
 
-import "reflect"
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 type rValue = reflect.Value
 
@@ -14,6 +21,46 @@ type Section struct {
 	Name    string
 	options Dict
 	lookup  Dict
+
+	// header is the original text of the header line of the section.
+	header string
+
+	// lines are the lines of the section, in order; they are used to write
+	// the section back without losing its comments and formatting.
+	lines []*sectionLine
+
+	// origins contains the layers which supplied the values of the options,
+	// by their lookup keys.
+	origins map[string]*ValueOrigin
+
+	// layers contains the values of the options which have been set by the
+	// other layers (the included and drop-in files, the environment
+	// variables and the flags); they override the options of the section,
+	// but they're not written back with it.
+	layers Dict
+
+	// layered is true if the section has been added by the other layers,
+	// and not by the parsed text or by the user.
+	layered bool
+}
+
+// sectionLine is an option, a comment or a blank line of a section.
+type sectionLine struct {
+	// key is the key of the option, or empty for the other lines.
+	key string
+
+	// raw is the original text of the line (or lines, for a multi-line
+	// value); it's empty if the option has been changed (or added) since
+	// the section was parsed.
+	raw string
+
+	// lineNo is the line number of the option in the parsed text, or 0 if
+	// the line isn't parsed.
+	lineNo int
+
+	// quoted is true if the value of the option was double-quoted, so it
+	// will be quoted again when it's changed.
+	quoted bool
 }
 
 // Dict is a simple string->string map.
@@ -28,6 +75,294 @@ type ConfigParser struct {
 	config   Config
 	defaults *Section
 	options  *ConfigParserOptions
+
+	// preamble contains the lines before the first section header.
+	preamble []string
+
+	// order contains the names of the sections, in the order they were
+	// parsed or added.
+	order []string
+
+	// includes contains the patterns of the include directives of the
+	// parsed text.
+	includes []string
+
+	// files contains the parsed files (and the drop-in directory), so they
+	// can be watched for changes.
+	files []string
+
+	// envValues contains the variables of the parsed .env files, which are
+	// used like the environment variables.
+	envValues map[string]string
+
+	// fieldErrors contains the invalid fields found while parsing a
+	// struct.
+	fieldErrors []*FieldError
+
+	// parseErrors contains the problems of the parsed INI text.
+	parseErrors []*ParseError
+}
+
+// ParseError describes a problem of a line of the parsed INI text.
+type ParseError struct {
+	// File is the name of the parsed file, if any.
+	File string
+
+	// Line and Column are the position of the problem; the columns start
+	// from 1.
+	Line   int
+	Column int
+
+	// Section and Key are the section and the key of the duplicate keys (or
+	// the duplicate section).
+	Section string
+	Key     string
+
+	// Text is the text of the line.
+	Text string
+
+	// Err is ErrDuplicateKey, ErrDuplicateSection, ErrUnrecognizedLine or
+	// ErrMissingSectionHeader.
+	Err error
+}
+
+// SyntaxError is returned when the parsed INI text has problems, either in
+// the strict syntax mode or when an option is placed before the first
+// section header.
+type SyntaxError struct {
+	Errors []*ParseError
+}
+
+// iniLexer reads the lines of an INI text, one by one, and classifies them.
+type iniLexer struct {
+	reader *bufio.Reader
+	lineNo int
+
+	// err is the error of reading the text, if any.
+	err error
+}
+
+// iniTokenKind is the kind of a line of an INI text.
+type iniTokenKind int
+
+// iniToken is a line of an INI text.
+type iniToken struct {
+	kind iniTokenKind
+	raw  string
+
+	// line is the line number of the line, and column is the column of its
+	// first non-whitespace character.
+	line   int
+	column int
+	indent int
+
+	// name is the name of the section or the key of the option, and value is
+	// the (raw) value of the option.
+	name  string
+	value string
+}
+
+// ValueSource is where the value of a field comes from.
+type ValueSource string
+
+// ValueOrigin describes the layer which supplied the value of an option.
+type ValueOrigin struct {
+	Source ValueSource
+
+	// Name is the name of the file, of the environment variable or of the
+	// flag which supplied the value.
+	Name string
+
+	// Line is the line number of the option in the file, or 0; Column is
+	// the column of its key.
+	Line   int
+	Column int
+}
+
+// FieldError describes a field of a config struct which has an invalid value.
+type FieldError struct {
+	// Field is the name of the field, prefixed by the name of its struct.
+	Field   string
+	Section string
+	Key     string
+
+	// Line is the line number of the option, or 0 if it's unknown; Column
+	// is the column of its key.
+	Line   int
+	Column int
+
+	// File is the name of the parsed file, if any.
+	File string
+
+	// Source is where the invalid value comes from.
+	Source ValueSource
+	Value  string
+	Err    error
+}
+
+// ConfigError is returned by the strongParser functions when one or more
+// fields of the config struct have invalid values.
+type ConfigError struct {
+	Errors []*FieldError
+}
+
+// Validator is implemented by the config structs which validate themselves;
+// Validate is called after the struct is parsed.
+type Validator interface {
+	Validate() error
+}
+
+// pendingOption is an option which is being parsed; its value may continue
+// in the next lines.
+type pendingOption struct {
+	key    string
+	indent int
+
+	// raw contains the original lines of the option.
+	raw []string
+
+	// values contains the value parts of the lines.
+	values []string
+
+	// lineNo is the line number of the first line of the option, and column
+	// is the column of its key.
+	lineNo int
+	column int
+}
+
+// ConfigWatcher re-parses a config file when it (or one of its layers)
+// changes, and keeps the latest valid config.
+type ConfigWatcher[T any] struct {
+	path    string
+	options *WatchOptions[T]
+	current atomic.Pointer[T]
+
+	// stamps contains the states of the watched files at the last reload.
+	stamps  map[string]fileStamp
+	lastErr error
+	mutex   *sync.Mutex
+
+	stopOnce *sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// WatchOptions are the options of a ConfigWatcher.
+type WatchOptions[T any] struct {
+	// Interval is the interval of checking the files for changes;
+	// DefaultWatchInterval is used if it's 0.
+	Interval time.Duration
+
+	// Notify is an optional channel which makes the watcher check the files
+	// immediately, such as when a file system notification is received.
+	Notify <-chan struct{}
+
+	// ParserOptions are the options used to parse the config.
+	ParserOptions *ConfigParserOptions
+
+	// OnChange is called after the config is reloaded, if any of its fields
+	// has changed. the callbacks are called without holding the lock of the
+	// watcher, so they can use its methods (such as Reload).
+	OnChange func(oldValue, newValue *T, changes []FieldChange)
+
+	// OnError is called when the config can't be reloaded; the old config
+	// is kept.
+	OnError func(err error)
+}
+
+// FieldChange describes a field which has changed after a reload.
+type FieldChange struct {
+	// Field is the path of the field, such as "Database.Url".
+	Field    string
+	OldValue any
+	NewValue any
+}
+
+// fileStamp is the state of a watched file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+// ConfigFormat decodes the text of a config format into the sections and
+// the options of a ConfigParser, so the tagged structs can be loaded from it.
+type ConfigFormat interface {
+	Decode(data []byte, opt *ConfigParserOptions) (*ConfigParser, error)
+}
+
+// ConfigFormatFunc is a function which implements ConfigFormat.
+type ConfigFormatFunc func(data []byte, opt *ConfigParserOptions) (*ConfigParser, error)
+
+// tomlDecoder decodes a TOML document.
+type tomlDecoder struct {
+	data string
+	pos  int
+	line int
+}
+
+// yamlDecoder decodes the lines of a YAML document.
+type yamlDecoder struct {
+	lines []yamlLine
+	pos   int
+}
+
+// yamlLine is a line of a YAML document.
+type yamlLine struct {
+	indent int
+	text   string
+	raw    string
+	lineNo int
+}
+
+// SecretResolver resolves the secret references of a prefix, such as
+// "file://" or "env://"; reference is the value without its prefix.
+type SecretResolver interface {
+	Resolve(reference string, opt *ConfigParserOptions) (string, error)
+}
+
+// SecretResolverFunc is a function which implements SecretResolver.
+type SecretResolverFunc func(reference string, opt *ConfigParserOptions) (string, error)
+
+// Secret is a string which is masked when it's printed; the fields of this
+// type are treated like the fields with the `secret:"true"` tag, which are
+// only masked by DumpConfig, so use this type for the values which may be
+// printed.
+type Secret string
+
+// ConfigSchema describes the options of a config struct; it's returned by
+// Describe.
+type ConfigSchema struct {
+	Fields []*FieldSchema
+}
+
+// FieldSchema describes the option of a field of a config struct.
+type FieldSchema struct {
+	// Field is the path of the field, such as "Database.Url"; the fields of
+	// the elements of a slice of structs are like "Owners[].Name".
+	Field string
+
+	// Section is the section of the option; the sections of the elements of
+	// a slice of structs are like "owners.<n>".
+	Section string
+
+	// Key is the key of the option, or "*" for the maps, which contain all
+	// of the options of their section.
+	Key  string
+	Type string
+
+	// Default is the `default` tag of the field; it's masked if the field
+	// is a secret.
+	Default string
+
+	// Env contains the environment variables which are tried for the value,
+	// in order.
+	Env      []string
+	Required bool
+	Secret   bool
+
+	// Description is the `desc` tag of the field.
+	Description string
 }
 
 type MainAndArrayContainer[mT any, mA any] struct {
@@ -35,13 +370,88 @@ type MainAndArrayContainer[mT any, mA any] struct {
 	Sections []*mA
 }
 
+// countingWriter writes to w, counting the written bytes and keeping the
+// first error.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
 type ChainMap struct {
 	maps []Dict
 }
 
+// InterpolationMode is the way the references in the values are replaced
+// by the values of the other options.
+type InterpolationMode int
+
 type ConfigParserOptions struct {
 	ReadEnv         bool
 	MainSectionName string
+
+	// Interpolation is the interpolation mode of the values; the values are
+	// not interpolated by default.
+	Interpolation InterpolationMode
+
+	// InlineCommentPrefixes are the prefixes which start a comment at the
+	// end of a value, such as ";" or "#"; the prefix has to be preceded by
+	// a whitespace. the comments are kept in the value if it's empty.
+	InlineCommentPrefixes []string
+
+	// DropInDir is the directory of the drop-in files ("*.ini"), which are
+	// parsed in the order of their names after the main file; a relative
+	// path is relative to the directory of the main file.
+	DropInDir string
+
+	// BaseDir is the directory of the include directives and of the
+	// DropInDir of the configs which are parsed from strings or bytes
+	// (instead of files). if it's empty, those configs never read any file:
+	// the DropInDir is ignored, and the include directives are an error
+	// (ErrNoBaseDir).
+	BaseDir string
+
+	// EnvOverrides makes the environment variables named "SECTION_KEY"
+	// override the options of the parsed files.
+	EnvOverrides bool
+
+	// EnvPrefix is the prefix of the environment variables of the fields
+	// (and of EnvOverrides); if it's set, the value of a field is only read
+	// from the variable named "PREFIX_SECTION_KEY" (or from its `env` tag),
+	// instead of trying "SECTION_KEY", "key" and "KEY".
+	EnvPrefix string
+
+	// PreferEnv makes the values of the environment variables take
+	// precedence over the values of the config, when the fields of a struct
+	// are parsed.
+	PreferEnv bool
+
+	// ReadFlags makes the "--section.key=value" flags of os.Args override
+	// the options; "--key=value" sets the option of the main section.
+	ReadFlags bool
+
+	// Args are the command-line arguments which are used instead of
+	// os.Args[1:] when ReadFlags is true.
+	Args []string
+
+	// Strict makes the parsing fail when a value can't be converted to the
+	// type of its field, instead of falling back to the other sources.
+	Strict bool
+
+	// StrictSyntax makes the parsing fail when the INI text has duplicate
+	// keys, duplicate sections or unrecognized lines; otherwise they are
+	// only returned by the ParseErrors method.
+	StrictSyntax bool
+
+	// ResolveSecrets makes Get resolve the secret references of all of the
+	// values, such as "file:///run/secrets/token", "env://BOT_TOKEN" or the
+	// "enc:" values; the references of the fields with the `secret:"true"`
+	// tag (or of the Secret type) are always resolved.
+	ResolveSecrets bool
+
+	// SecretKeySource is the secret reference (such as "env://CONFIG_KEY")
+	// of the base64-encoded AES-GCM key which decrypts the "enc:" values.
+	SecretKeySource string
 }
 
 type SectionValue interface {
@@ -50,3 +460,7 @@ type SectionValue interface {
 }
 
 type fieldValueConverter[T comparable] func(fType, fValue string) (T, error)
+
+// valueConverter converts the string value to a value of a certain type;
+// fType is the `type` tag of the field (if any).
+type valueConverter func(fType, fValue string) (rValue, error)
@@ -1,14 +1,54 @@
 package strongParser
 
 import (
+	"encoding"
+	"errors"
+	"net/url"
 	"reflect"
 	"regexp"
+	"strings"
+	"sync"
+	"time"
 )
 
 var (
-	sectionHeader      = regexp.MustCompile(`\[([^]]+)\]`)
-	keyValue           = regexp.MustCompile(`([^:=\s][^:=]*)\s*(?P<vi>[:=])\s*(.*)$`)
+	// ErrInterpolationCycle is returned when the references of a value
+	// refer back to the value itself.
+	ErrInterpolationCycle = errors.New("interpolation cycle")
+
+	// ErrInterpolationDepth is returned when a value has too many nested
+	// references.
+	ErrInterpolationDepth = errors.New("interpolation depth exceeded")
+
+	// ErrSecretResolution is returned when a secret reference can't be
+	// resolved.
+	ErrSecretResolution = errors.New("can't resolve secret")
+
+	// ErrDuplicateKey is the error of a ParseError of a key which appears
+	// more than once in a section.
+	ErrDuplicateKey = errors.New("duplicate key")
+
+	// ErrDuplicateSection is the error of a ParseError of a section header
+	// which appears more than once.
+	ErrDuplicateSection = errors.New("duplicate section")
+
+	// ErrUnrecognizedLine is the error of a ParseError of a line which is not
+	// a section header, an option or a comment.
+	ErrUnrecognizedLine = errors.New("unrecognized line")
+
+	// ErrMissingSectionHeader is the error of a ParseError of an option which
+	// is placed before the first section header.
+	ErrMissingSectionHeader = errors.New("missing section header")
+
+	// ErrNoBaseDir is returned when a config which isn't parsed from a file
+	// has include directives, but the BaseDir option isn't set.
+	ErrNoBaseDir = errors.New("include directive without a base directory")
+
+	keyValue           = regexp.MustCompile(`^([^:=\s][^:=]*)\s*(?P<vi>[:=])\s*(.*)$`)
 	DefaultMainSection = "main"
+
+	// quoteReplacer escapes the characters of a double-quoted value.
+	quoteReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
 )
 
 // BoolMapping is a map of strings to bool.
@@ -31,3 +71,70 @@ var BoolMapping = map[string]bool{
 }
 
 var invalidReflectValue = reflect.ValueOf(nil)
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// customConverters contains the converters of the types which aren't
+// converted by their kind; they are registered by RegisterConverter.
+var customConverters = map[reflect.Type]valueConverter{
+	reflect.TypeFor[time.Duration](): toValueConverter(extractDuration),
+	reflect.TypeFor[time.Time]():     toValueConverter(extractTime),
+	reflect.TypeFor[url.URL]():       toValueConverter(extractURL),
+}
+
+var customConvertersMutex = &sync.RWMutex{}
+
+var (
+	// INIFormat is the INI format, which is the default config format.
+	INIFormat ConfigFormat = ConfigFormatFunc(decodeINI)
+
+	// JSONFormat is the JSON format; see newParserFromTree for how the
+	// values are mapped to the sections.
+	JSONFormat ConfigFormat = ConfigFormatFunc(decodeJSON)
+
+	// TOMLFormat is the TOML format; the integers, floats and dates are
+	// kept as they are written.
+	TOMLFormat ConfigFormat = ConfigFormatFunc(decodeTOML)
+
+	// YAMLFormat is a subset of the YAML format; see decodeYAMLTree.
+	YAMLFormat ConfigFormat = ConfigFormatFunc(decodeYAML)
+
+	// EnvFormat is the format of the .env files, whose variables are used
+	// like the environment variables.
+	EnvFormat ConfigFormat = ConfigFormatFunc(decodeEnv)
+)
+
+// configFormats contains the config formats by the extensions of the files.
+var configFormats = map[string]ConfigFormat{
+	".ini":  INIFormat,
+	".cfg":  INIFormat,
+	".conf": INIFormat,
+	".json": JSONFormat,
+	".toml": TOMLFormat,
+	".yaml": YAMLFormat,
+	".yml":  YAMLFormat,
+	".env":  EnvFormat,
+}
+
+var configFormatsMutex = &sync.RWMutex{}
+
+// markdownCellReplacer escapes the text of a Markdown table cell.
+var markdownCellReplacer = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+
+var secretType = reflect.TypeFor[Secret]()
+
+// secretResolvers contains the resolvers of the secret references by their
+// prefixes; they are registered by RegisterSecretResolver.
+var secretResolvers = map[string]SecretResolver{
+	FileSecretPrefix:      SecretResolverFunc(resolveFileSecret),
+	EnvSecretPrefix:       SecretResolverFunc(resolveEnvSecret),
+	EncryptedSecretPrefix: SecretResolverFunc(resolveEncryptedSecret),
+}
+
+var secretResolversMutex = &sync.RWMutex{}
+
+// secretReferences contains the references of the resolved values of the
+// secret fields (such as "file:///run/secrets/token"), by their values.
+var secretReferences = make(map[string]string)
+
+var secretReferencesMutex = &sync.RWMutex{}
@@ -6,7 +6,10 @@
 package ssg
 
 import (
+	"context"
 	"hash"
+	"io"
+	"iter"
 	"sync"
 	"time"
 
@@ -16,7 +19,15 @@ import (
 
 type ExpiringValue[T any] struct {
 	_value T
-	_t     time.Time
+	// _t is the unix-nano time of the last reset of the value. it's accessed
+	// atomically, since maps may reset it while holding only a read lock.
+	_t int64
+	// _ttl is the lifetime of this specific value; zero means that the
+	// default expiration of the container should be used.
+	_ttl time.Duration
+	// _index is the index of the value in the expiry queue of its container,
+	// or -1 if the value isn't scheduled.
+	_index int
 }
 
 // the StrongString used in the program for additional usage.
@@ -28,6 +39,15 @@ type ListW[T comparable] struct {
 	_values []T
 }
 
+// SafeList is a thread safe list of elements of type T.
+// all of the methods of this list are using an internal lock, and it also
+// provides some compound operations (such as AppendIfAbsent and RemoveWhere)
+// which are done atomically.
+type SafeList[T comparable] struct {
+	mut     *sync.RWMutex
+	_values []T
+}
+
 // AdvancedMap is a safe map of type TIndex to pointers of type TValue with
 // extra advanced features that you can't find in safe-map types.
 // obviously, because of its extra features, it's slightly slower than other
@@ -46,6 +66,69 @@ type AdvancedMap[TKey comparable, TValue any] struct {
 	// _default field is the default value this map has to return in GetValue
 	// method when the key is not found.
 	_default TValue
+
+	// events holds the change handlers and watchers of the map.
+	events *mapEvents[TKey, TValue]
+}
+
+// LRUMap is a safe map of type TIndex to pointers of type TValue which can
+// hold at most a certain count of values. when it's full, adding a new value
+// evicts the least recently used value (or the least frequently used one,
+// depending on its eviction policy).
+// values can optionally expire after a certain duration as well.
+// this map is completely thread safe and is using internal lock when
+// getting and setting variables.
+type LRUMap[TKey comparable, TValue any] struct {
+	mut    *sync.Mutex
+	values map[TKey]*lruEntry[TKey, TValue]
+	// queue is a min-heap of the entries, the first entry is the one which
+	// has to be evicted first. it's also used for choosing random entries,
+	// the same way AdvancedMap uses its keys slice.
+	queue lruQueue[TKey, TValue]
+	// maxEntries is the maximum count of the values; zero means no limit.
+	maxEntries int
+	policy     EvictionPolicy
+	// expiration is the lifetime of the values; zero means no expiration.
+	expiration time.Duration
+	// clock is a logical clock used for ordering the accesses.
+	clock  uint64
+	hits   uint64
+	misses uint64
+	// _default field is the default value this map has to return in GetValue
+	// method when the key is not found.
+	_default TValue
+
+	// onEvicted is the event function that will be called when a value is
+	// evicted because the map is full.
+	onEvicted func(key TKey, value TValue)
+	// onExpired is the event function that will be called when an expired
+	// value is removed from the map.
+	onExpired func(key TKey, value TValue)
+}
+
+// EvictionPolicy determines which value has to be evicted from an LRUMap
+// when it's full.
+type EvictionPolicy int
+
+// lruEntry is an entry of LRUMap.
+type lruEntry[TKey comparable, TValue any] struct {
+	key   TKey
+	value *TValue
+	// frequency is the count of the accesses to the entry.
+	frequency uint64
+	// lastAccess is the logical time of the last access to the entry.
+	lastAccess uint64
+	// addedAt is the time at which the value was set.
+	addedAt time.Time
+	// index is the index of the entry in the queue of the map.
+	index int
+}
+
+// lruQueue is a min-heap of the entries of an LRUMap, ordered by the eviction
+// policy of the map. it implements heap.Interface.
+type lruQueue[TKey comparable, TValue any] struct {
+	entries []*lruEntry[TKey, TValue]
+	policy  EvictionPolicy
 }
 
 // SafeMap is a safe map of type TIndex to pointers of type TValue.
@@ -61,8 +144,32 @@ type SafeMap[TKey comparable, TValue any] struct {
 
 	// _disabled determines whether the map is disabled or not.
 	_disabled bool
+
+	// events holds the change handlers and watchers of the map.
+	events *mapEvents[TKey, TValue]
+}
+
+// ShardedSafeMap is a safe map of type TIndex to pointers of type TValue
+// which splits its values between multiple SafeMap shards, each with its own
+// lock, so goroutines working on different keys won't block each other.
+// it provides the same methods as SafeMap, so it can be used instead of it
+// for hot maps which are accessed by lots of goroutines at the same time.
+type ShardedSafeMap[TKey comparable, TValue any] struct {
+	shards []*SafeMap[TKey, TValue]
+
+	// hasher is the function used for choosing the shard of a key.
+	hasher ShardHasher[TKey]
+
+	// _default field is the default value this map has to return in GetValue
+	// method when the key is not found.
+	_default TValue
 }
 
+// ShardHasher is a function which returns the hash of the given key.
+// it's used by ShardedSafeMap for choosing the shard of each key, so it
+// should always return the same value for the same key.
+type ShardHasher[TKey comparable] func(key TKey) uint64
+
 type NumIdGenerator[T rangeValues.Integer] struct {
 	current T
 	mut     *sync.Mutex
@@ -71,15 +178,19 @@ type NumIdGenerator[T rangeValues.Integer] struct {
 // SafeEMap is a safe map of type TIndex to pointers of type TValue.
 // this map is completely thread safe and is using internal lock when
 // getting and setting variables.
-// the difference of SafeEMap and SafeMap is that SafeEMap is using a checker loop
-// for removing the expired values from itself.
+// the difference of SafeEMap and SafeMap is that SafeEMap removes the expired
+// values from itself. the values are kept in a queue ordered by their
+// deadlines, so only the values which are actually due are touched when
+// checking for the expired values.
 type SafeEMap[TKey comparable, TValue any] struct {
-	checkingEnabled bool
-	checkInterval   time.Duration
-	expiration      time.Duration
-	mut             *sync.RWMutex
-	checkerMut      *sync.Mutex
-	values          map[TKey]*ExpiringValue[*TValue]
+	// expiration is the default lifetime of the values; values added with
+	// their own TTL will use that instead.
+	expiration time.Duration
+	// sliding determines whether reading a value should refresh its lifetime.
+	sliding    bool
+	mut        *sync.RWMutex
+	checkerMut *sync.Mutex
+	values     map[TKey]*ExpiringValue[*TValue]
 	// keys field is a slice of the map keys used in the map above. We put them in a slice
 	// so that we can get a random key by choosing a random index.
 	keys []TKey
@@ -88,12 +199,158 @@ type SafeEMap[TKey comparable, TValue any] struct {
 	sliceKeyIndex map[TKey]int
 	_default      TValue
 
+	// queue is a min-heap of the values of the map, ordered by their deadlines.
+	queue expiryQueue[TKey, TValue]
+	// wakeChan is used for waking the checker loop up when a value with an
+	// earlier deadline than the ones it's waiting for is added.
+	wakeChan chan struct{}
+	// cancelChecking stops the checker loop; it's nil if the loop isn't running.
+	cancelChecking context.CancelFunc
+	// checkingDone is closed when the checker loop exits.
+	checkingDone chan struct{}
+
 	// _disabled determines whether the map is disabled or not.
 	_disabled bool
 
-	// onExpired is the event function that will be called when a value with the certain
-	// key on the map is expired. this event function will be called in a new goroutine.
-	onExpired func(key TKey, value TValue)
+	// events holds the change handlers and watchers of the map, including
+	// the handler set by SetOnExpired.
+	events *mapEvents[TKey, TValue]
+}
+
+// expiryItem is an entry of the expiry queue of SafeEMap.
+type expiryItem[TKey comparable, TValue any] struct {
+	key   TKey
+	value *ExpiringValue[*TValue]
+	// deadline is the unix-nano time at which the value was scheduled to
+	// expire. the real deadline of the value may be later than this (e.g.
+	// because it was read from a sliding map), in which case the item is
+	// rescheduled when it's due.
+	deadline int64
+}
+
+// expiryQueue is a min-heap of expiry items ordered by their deadlines.
+// it implements heap.Interface.
+type expiryQueue[TKey comparable, TValue any] []*expiryItem[TKey, TValue]
+
+// MapEventKind is the kind of a change made to a map.
+type MapEventKind int
+
+// MapEvent describes a change made to a map.
+type MapEvent[TKey comparable, TValue any] struct {
+	Kind MapEventKind
+	Key  TKey
+	// Value is the new value of the key for add and update events, and the
+	// removed value for delete and expire events.
+	Value TValue
+	// OldValue is the previous value of the key; it's only set for update
+	// events.
+	OldValue TValue
+}
+
+// EventDeliveryMode determines how the change handlers of a map are called.
+type EventDeliveryMode int
+
+// EventDropPolicy determines what happens to a change event when the queue it
+// has to be delivered to is full.
+type EventDropPolicy int
+
+// MapEventOptions are the options used for delivering the change events of
+// a map to its handlers and watchers.
+type MapEventOptions struct {
+	// Mode is the delivery mode of the handlers; watchers always receive the
+	// events through their own channels.
+	Mode EventDeliveryMode
+	// Workers is the count of the goroutines calling the handlers in
+	// EventDeliveryPool mode.
+	Workers int
+	// BufferSize is the size of the queue of the handlers in the asynchronous
+	// modes, and the size of the channels returned by Watch.
+	BufferSize int
+	// DropPolicy determines what happens to the events when a queue is full.
+	DropPolicy EventDropPolicy
+}
+
+// mapEvents holds the change handlers and watchers of a map and delivers
+// the events to them.
+type mapEvents[TKey comparable, TValue any] struct {
+	mut     *sync.RWMutex
+	options MapEventOptions
+	// active is the count of the registered handlers and watchers; it's
+	// accessed atomically, so maps without any observer won't pay for events.
+	active int32
+
+	onAdd    []func(key TKey, value TValue)
+	onUpdate []func(key TKey, oldValue, value TValue)
+	onDelete []func(key TKey, value TValue)
+	onClear  []func()
+	onExpire func(key TKey, value TValue)
+
+	watchers map[*mapWatcher[TKey, TValue]]bool
+	// queue is the queue of the handlers in the asynchronous modes; it's nil
+	// in EventDeliverySync mode.
+	queue *eventQueue[TKey, TValue]
+}
+
+// mapWatcher is a channel returned by the Watch method of a map.
+type mapWatcher[TKey comparable, TValue any] struct {
+	// mut guards the channel against being closed while an event is sent.
+	mut     *sync.Mutex
+	ctx     context.Context
+	channel chan MapEvent[TKey, TValue]
+	closed  bool
+}
+
+// eventQueue is the queue of the events which are delivered to the handlers
+// by the workers of the asynchronous modes.
+type eventQueue[TKey comparable, TValue any] struct {
+	// mut guards the channel against being closed while an event is sent.
+	mut     *sync.RWMutex
+	channel chan MapEvent[TKey, TValue]
+	// done is closed before closing the queue, so the senders which are
+	// waiting for room in the channel stop waiting.
+	done   chan struct{}
+	closed bool
+}
+
+// MapSnapshot is the serializable form of the values of a map, used by the
+// SaveTo and LoadFrom methods of the maps.
+type MapSnapshot[TKey comparable, TValue any] struct {
+	Entries []SnapshotEntry[TKey, TValue]
+}
+
+// SnapshotEntry is a single key-value pair of a MapSnapshot.
+type SnapshotEntry[TKey comparable, TValue any] struct {
+	Key   TKey
+	Value *TValue
+
+	// Time is the unix-nano time of the last reset of the value; it's only
+	// used by expiring maps.
+	Time int64 `json:",omitempty"`
+	// TTL is the lifetime of the value, if it has its own lifetime; it's only
+	// used by expiring maps.
+	TTL time.Duration `json:",omitempty"`
+}
+
+// SnapshotCodec encodes and decodes map snapshots.
+type SnapshotCodec interface {
+	Encode(w io.Writer, value any) error
+	Decode(r io.Reader, value any) error
+}
+
+// JSONSnapshotCodec is a SnapshotCodec which uses encoding/json.
+type JSONSnapshotCodec struct{}
+
+// GobSnapshotCodec is a SnapshotCodec which uses encoding/gob.
+type GobSnapshotCodec struct{}
+
+// Snapshotter is implemented by the maps which can save their values.
+type Snapshotter interface {
+	SaveToWithCodec(w io.Writer, codec SnapshotCodec) error
+}
+
+// SnapshotLoader is implemented by the maps which can load their values.
+type SnapshotLoader interface {
+	LoadFromWithCodec(r io.Reader, codec SnapshotCodec) error
 }
 
 // EndpointResponse is the generalized form of a response from a HTTP API.
@@ -133,8 +390,6 @@ type UInt8Container = rangeValues.IntContainer[uint8]
 
 type ExecuteCommandResult = shellUtils.ExecuteCommandResult
 
-//type safeList[T any] #TODO: implement safe-list
-
 type StringUniqueIdContainer = UniqueIdContainer[string]
 type Int64UniqueIdContainer = UniqueIdContainer[int64]
 
@@ -209,6 +464,18 @@ type GenericList[T comparable] interface {
 	ToArray() []T
 	Clear()
 	Get(index int) T
+	All() iter.Seq2[int, T]
+	Values() iter.Seq[T]
+	Filter(fn func(element T) bool) GenericList[T]
+	Where(fn func(element T) bool) GenericList[T]
+	FindFunc(fn func(element T) bool) int
+	IndexOf(element T) int
+	SortFunc(cmp func(a, b T) int) GenericList[T]
+	Distinct() GenericList[T]
+	Reverse() GenericList[T]
+	Chunk(size int) []GenericList[T]
+	Insert(index int, elements ...T) GenericList[T]
+	Slice(from, to int) GenericList[T]
 }
 
 type BytesObject interface {
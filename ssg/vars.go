@@ -1,6 +1,8 @@
 package ssg
 
 import (
+	"errors"
+
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
@@ -8,3 +10,11 @@ import (
 var (
 	_titleCaser = cases.Title(language.Und, cases.NoLower)
 )
+
+// DefaultSnapshotCodec is the codec used by the SaveTo and LoadFrom methods
+// of the maps.
+var DefaultSnapshotCodec SnapshotCodec = JSONSnapshotCodec{}
+
+// ErrInvalidSnapshotInterval is returned by StartAutoSnapshot when the
+// interval isn't positive.
+var ErrInvalidSnapshotInterval = errors.New("ssg: the snapshot interval must be positive")
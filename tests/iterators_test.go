@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/AnimeKaizoku/ssg/ssg"
+)
+
+func TestMapIterators(t *testing.T) {
+	m := ssg.NewAdvancedMap[int, string]()
+	m.Set(1, "one")
+	m.Set(2, "two")
+	m.Set(3, "three")
+
+	keys := slices.Collect(m.Keys())
+	if !slices.Equal(keys, []int{1, 2, 3}) {
+		t.Error("Expected the keys in insertion order, got:", keys)
+		return
+	}
+
+	collected := maps.Collect(m.All())
+	if len(collected) != 3 || *collected[2] != "two" {
+		t.Error("Expected all of the pairs to be collected, got:", collected)
+		return
+	}
+
+	// the loop body should be able to modify the map, without seeing
+	// the changes in the same iteration.
+	count := 0
+	for key := range m.All() {
+		m.Set(key+10, "new")
+		m.Delete(key)
+		count++
+	}
+
+	if count != 3 || m.Length() != 3 || m.Exists(1) {
+		t.Error("Expected the iteration to run over a snapshot, got:", count, m.ToNormalMap())
+		return
+	}
+
+	m2 := ssg.NewSafeEMap[string, int]()
+	m2.Set("a", 1)
+	m2.Set("b", 2)
+	sum := 0
+	for value := range m2.Values() {
+		sum += *value
+	}
+
+	if sum != 3 {
+		t.Error("Expected the sum of the values to be 3, got:", sum)
+		return
+	}
+
+	m3 := ssg.NewShardedSafeMap[int, int](4)
+	for i := 0; i < 100; i++ {
+		m3.Set(i, i)
+	}
+
+	sharded := slices.Sorted(m3.Keys())
+	if len(sharded) != 100 || sharded[99] != 99 {
+		t.Error("Expected 100 keys from the sharded map, got:", len(sharded))
+		return
+	}
+
+	for range m3.All() {
+		// breaking out of the loop should stop the iteration.
+		break
+	}
+}
+
+func TestListIterators(t *testing.T) {
+	list := ssg.GetListFromArray([]int{3, 1, 2})
+	values := slices.Sorted(list.Values())
+	if !slices.Equal(values, []int{1, 2, 3}) {
+		t.Error("Expected sorted values, got:", values)
+		return
+	}
+
+	for i, value := range list.All() {
+		if list.Get(i) != value {
+			t.Error("Expected the index to match the value, got:", i, value)
+			return
+		}
+	}
+
+	safeList := ssg.GetSafeListFromArray([]int{1, 2, 3})
+	for _, value := range safeList.All() {
+		safeList.Append(value)
+	}
+
+	if safeList.Length() != 6 {
+		t.Error("Expected 6 elements in the safe list, got:", safeList.Length())
+		return
+	}
+}
@@ -1,6 +1,9 @@
 package tests
 
 import (
+	"slices"
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/AnimeKaizoku/ssg/ssg"
@@ -31,3 +34,123 @@ func TestList01(t *testing.T) {
 	}
 
 }
+
+func TestSafeList01(t *testing.T) {
+	l1 := ssg.NewSafeList[int]()
+	var wg sync.WaitGroup
+
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(value int) {
+			defer wg.Done()
+			l1.Add(value)
+			l1.AppendIfAbsent(value % 8)
+		}(i)
+	}
+	wg.Wait()
+
+	// AppendIfAbsent may or may not run before the Add of the same value.
+	if l1.Length() < 64 || l1.Length() > 72 {
+		t.Error("Expected 64 to 72 elements, got:", l1.Length())
+		return
+	}
+
+	removed := l1.RemoveWhere(func(element int) bool {
+		return element >= 8
+	})
+	if removed != 56 {
+		t.Error("Expected 56 removed elements, got:", removed)
+		return
+	}
+
+	l1.RemoveAll(0, 1)
+	if l1.ContainsOne(0, 1) {
+		t.Error("Expected 0 and 1 to be removed, got:", l1.AsArray())
+		return
+	}
+
+	count := 0
+	l1.ForEach(func(index int, element int) bool {
+		l1.Add(element)
+		count++
+		return false
+	})
+
+	if l1.Length() != count*2 {
+		t.Error("Expected", count*2, "elements, got:", l1.Length())
+		return
+	}
+
+	if l1.Get(l1.Length()) != 0 {
+		t.Error("Expected zero value for out of range index")
+		return
+	}
+}
+
+func TestListQuery01(t *testing.T) {
+	lists := []ssg.GenericList[int]{
+		ssg.GetListFromArray([]int{5, 3, 8, 3, 1, 8, 2}),
+		ssg.GetSafeListFromArray([]int{5, 3, 8, 3, 1, 8, 2}),
+	}
+
+	for _, l1 := range lists {
+		result := l1.Where(func(element int) bool {
+			return element > 1
+		}).Distinct().SortFunc(func(a, b int) int {
+			return a - b
+		}).Reverse().AsArray()
+
+		if !slices.Equal(result, []int{8, 5, 3, 2}) {
+			t.Error("Expected [8 5 3 2], got:", result)
+			return
+		}
+
+		if l1.FindFunc(func(element int) bool { return element > 5 }) != 2 || l1.IndexOf(1) != 4 {
+			t.Error("Unexpected result for FindFunc or IndexOf")
+			return
+		}
+
+		chunks := l1.Chunk(3)
+		if len(chunks) != 3 || chunks[2].Length() != 1 || chunks[1].Get(0) != 3 {
+			t.Error("Expected 3 chunks, got:", len(chunks))
+			return
+		}
+
+		sliced := l1.Slice(5, 100).Insert(0, 0).AsArray()
+		if !slices.Equal(sliced, []int{0, 8, 2}) {
+			t.Error("Expected [0 8 2], got:", sliced)
+			return
+		}
+
+		doubled := ssg.MapList(l1, func(element int) string {
+			return strconv.Itoa(element * 2)
+		})
+		_, isSafe := l1.(*ssg.SafeList[int])
+		_, isDoubledSafe := doubled.(*ssg.SafeList[string])
+		if doubled.Get(0) != "10" || isSafe != isDoubledSafe {
+			t.Error("Unexpected result for MapList:", doubled.AsArray())
+			return
+		}
+
+		sum := ssg.Reduce(l1, 0, func(result int, element int) int {
+			return result + element
+		})
+		if sum != 30 {
+			t.Error("Expected 30 for sum, got:", sum)
+			return
+		}
+
+		groups := ssg.GroupBy(l1, func(element int) bool {
+			return element%2 == 0
+		})
+		if !slices.Equal(groups[true].AsArray(), []int{8, 8, 2}) || groups[false].Length() != 4 {
+			t.Error("Unexpected groups:", groups[true].AsArray(), groups[false].AsArray())
+			return
+		}
+
+		if l1.Length() != 7 {
+			t.Error("Expected the original list to stay unchanged, got:", l1.AsArray())
+			return
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AnimeKaizoku/ssg/ssg"
+)
+
+func TestLRUMap01(t *testing.T) {
+	m := ssg.NewLRUMap[string, int](3)
+
+	var evicted []string
+	m.SetOnEvicted(func(key string, value int) {
+		evicted = append(evicted, key)
+	})
+
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("c", 3)
+
+	// "a" becomes the most recently used one, so "b" has to be evicted.
+	_ = m.Get("a")
+	m.Set("d", 4)
+
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Error("Expected b to be evicted, got:", evicted)
+		return
+	}
+
+	if m.Length() != 3 || m.Exists("b") {
+		t.Error("Expected a, c and d to remain, got:", m.ToNormalMap())
+		return
+	}
+
+	_ = m.Get("b")
+	hits, misses := m.GetStats()
+	if hits != 1 || misses != 1 {
+		t.Error("Expected 1 hit and 1 miss, got:", hits, misses)
+		return
+	}
+
+	// peeking shouldn't count as a use.
+	_ = m.Peek("c")
+	m.Set("e", 5)
+	if m.Exists("c") {
+		t.Error("Expected c to be evicted after peek")
+		return
+	}
+
+	m.SetMaxEntries(1)
+	if m.Length() != 1 || !m.Exists("e") {
+		t.Error("Expected only e to remain, got:", m.ToNormalMap())
+		return
+	}
+}
+
+func TestLFUMap01(t *testing.T) {
+	m := ssg.NewLFUMap[int, int](2)
+	m.Set(1, 1)
+	m.Set(2, 2)
+
+	for i := 0; i < 5; i++ {
+		_ = m.Get(1)
+	}
+	_ = m.Get(2)
+
+	// 2 was used more recently, but less frequently.
+	m.Set(3, 3)
+	if m.Exists(2) || !m.Exists(1) || !m.Exists(3) {
+		t.Error("Expected 2 to be evicted, got:", m.ToNormalMap())
+		return
+	}
+}
+
+func TestLRUMapExpiration(t *testing.T) {
+	m := ssg.NewLRUMap[int, int](10)
+	m.SetExpiration(20 * time.Millisecond)
+
+	var expired []int
+	m.SetOnExpired(func(key int, value int) {
+		expired = append(expired, key)
+	})
+
+	m.Set(1, 1)
+	m.Set(2, 2)
+	time.Sleep(40 * time.Millisecond)
+	m.Set(3, 3)
+
+	if m.Get(1) != nil {
+		t.Error("Expected 1 to be expired")
+		return
+	}
+
+	if removed := m.RemoveExpired(); removed != 1 {
+		t.Error("Expected 1 removed value, got:", removed)
+		return
+	}
+
+	if len(expired) != 2 || m.Length() != 1 {
+		t.Error("Expected 1 and 2 to be expired, got:", expired)
+		return
+	}
+
+	// the random values have to skip the expired values as well.
+	m.Set(4, 4)
+	time.Sleep(40 * time.Millisecond)
+	m.Set(5, 5)
+	for i := 0; i < 10; i++ {
+		if key, ok := m.GetRandomKey(); !ok || key != 5 {
+			t.Error("Expected the random key to be 5, got:", key, ok)
+			return
+		}
+
+		if value := m.GetRandomValue(); value != 5 {
+			t.Error("Expected the random value to be 5, got:", value)
+			return
+		}
+	}
+
+	if len(expired) != 4 || m.Length() != 1 {
+		t.Error("Expected 3 and 4 to be expired, got:", expired)
+		return
+	}
+}
+
+func TestLRUMapEvictExpiredFirst(t *testing.T) {
+	m := ssg.NewLFUMap[int, int](2)
+	m.SetExpiration(20 * time.Millisecond)
+
+	var evicted, expired []int
+	m.SetOnEvicted(func(key int, value int) {
+		evicted = append(evicted, key)
+	})
+	m.SetOnExpired(func(key int, value int) {
+		expired = append(expired, key)
+	})
+
+	// 1 is used the most, but it expires before the map is full.
+	m.Set(1, 1)
+	_ = m.Get(1)
+	_ = m.Get(1)
+	time.Sleep(40 * time.Millisecond)
+	m.Set(2, 2)
+	m.Set(3, 3)
+
+	if len(evicted) != 0 || len(expired) != 1 || expired[0] != 1 {
+		t.Error("Expected only 1 to be removed as expired, got:", evicted, expired)
+		return
+	}
+
+	if !m.Exists(2) || !m.Exists(3) {
+		t.Error("Expected 2 and 3 to remain, got:", m.ToNormalMap())
+		return
+	}
+
+	// fn is called without holding the lock, so it can use the map.
+	m.ForEach(func(key int, value *int) bool {
+		return m.Peek(key) != nil && key == 2
+	})
+
+	if m.Exists(2) || !m.Exists(3) {
+		t.Error("Expected only 2 to be deleted, got:", m.ToNormalMap())
+		return
+	}
+}
@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AnimeKaizoku/ssg/ssg"
+)
+
+func TestMapEvents01(t *testing.T) {
+	m := ssg.NewSafeMap[string, int]()
+
+	var added, updated, deleted, cleared int
+	m.OnAdd(func(key string, value int) {
+		added += value
+	})
+	m.OnUpdate(func(key string, oldValue, value int) {
+		if oldValue != 1 || value != 2 {
+			t.Error("Expected update from 1 to 2, got:", oldValue, value)
+		}
+		updated++
+	})
+	m.OnDelete(func(key string, value int) {
+		deleted += value
+	})
+	m.OnClear(func() {
+		cleared++
+	})
+
+	m.Set("a", 1)
+	m.Set("a", 2)
+	m.Set("b", 3)
+	m.Delete("a")
+	m.Delete("not-found")
+	m.Clear()
+
+	if added != 4 || updated != 1 || deleted != 2 || cleared != 1 {
+		t.Error("Unexpected event counts:", added, updated, deleted, cleared)
+		return
+	}
+
+	// handlers should be able to use the map without deadlocking.
+	m2 := ssg.NewAdvancedMap[int, int]()
+	m2.OnAdd(func(key int, value int) {
+		if !m2.Exists(key) {
+			t.Error("Expected key to exist in the handler:", key)
+		}
+	})
+	m2.OnDelete(func(key int, value int) {
+		m2.Set(-key, value)
+	})
+
+	m2.Set(1, 1)
+	m2.ForEach(func(key int, value *int) bool {
+		return key > 0
+	})
+
+	if m2.Exists(1) || m2.GetValue(-1) != 1 {
+		t.Error("Expected -1 to be added by OnDelete, got:", m2.ToNormalMap())
+		return
+	}
+}
+
+func TestMapEventsWatch(t *testing.T) {
+	m := ssg.NewAdvancedMap[int, string]()
+	m.SetEventOptions(ssg.MapEventOptions{
+		BufferSize: 2,
+		DropPolicy: ssg.EventDropOldest,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := m.Watch(ctx)
+
+	m.Set(1, "one")
+	m.Set(2, "two")
+	m.Set(1, "uno")
+
+	first := <-events
+	second := <-events
+	if first.Kind != ssg.MapEventAdd || first.Key != 2 {
+		t.Error("Expected the oldest event to be dropped, got:", first)
+		return
+	}
+
+	if second.Kind != ssg.MapEventUpdate || second.OldValue != "one" || second.Value != "uno" {
+		t.Error("Expected an update event, got:", second)
+		return
+	}
+
+	cancel()
+	for range events {
+		// the channel should be closed after the context is done.
+	}
+}
+
+func TestMapEventsPool(t *testing.T) {
+	m := ssg.NewSafeMap[int, int]()
+	m.SetEventOptions(ssg.MapEventOptions{
+		Mode:       ssg.EventDeliveryPool,
+		Workers:    4,
+		BufferSize: 8,
+		DropPolicy: ssg.EventBlock,
+	})
+
+	var count int64
+	wg := &sync.WaitGroup{}
+	wg.Add(100)
+	m.OnAdd(func(key int, value int) {
+		atomic.AddInt64(&count, 1)
+		wg.Done()
+	})
+
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+
+	wg.Wait()
+	if atomic.LoadInt64(&count) != 100 {
+		t.Error("Expected 100 add events, got:", count)
+		return
+	}
+}
+
+func TestMapEventsBlockingQueue(t *testing.T) {
+	m := ssg.NewSafeMap[int, int]()
+	for i := 0; i < 10; i++ {
+		m.Set(i, i)
+	}
+
+	m.SetEventOptions(ssg.MapEventOptions{
+		Mode:       ssg.EventDeliveryBuffered,
+		BufferSize: 1,
+		DropPolicy: ssg.EventBlock,
+	})
+
+	release := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(10)
+	m.OnDelete(func(key int, value int) {
+		<-release
+		wg.Done()
+	})
+
+	// all of the delete events are emitted at once, so the sender blocks on
+	// the full queue, while registering another handler waits for the lock
+	// of the events.
+	go m.ForEach(func(key int, value *int) bool {
+		return true
+	})
+	time.Sleep(20 * time.Millisecond)
+
+	registered := make(chan struct{})
+	go func() {
+		m.OnAdd(func(key int, value int) {})
+		close(registered)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		<-registered
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the events to be delivered without a deadlock")
+	}
+}
+
+func TestSafeEMapExpireEvents(t *testing.T) {
+	m := ssg.NewSafeEMap[string, int]()
+	m.SetExpiration(time.Hour)
+
+	expired := make(chan string, 1)
+	m.SetOnExpired(func(key string, value int) {
+		if value != 7 {
+			t.Error("Expected value 7 for the expired key, got:", value)
+		}
+		expired <- key
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := m.Watch(ctx)
+
+	m.SetWithTTL("short", 7, 10*time.Millisecond)
+	m.EnableChecking()
+	defer m.Close()
+
+	select {
+	case key := <-expired:
+		if key != "short" {
+			t.Error("Expected short to be expired, got:", key)
+			return
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the expire event to be called")
+		return
+	}
+
+	add := <-events
+	expire := <-events
+	if add.Kind != ssg.MapEventAdd || expire.Kind != ssg.MapEventExpire {
+		t.Error("Expected add and expire events, got:", add, expire)
+		return
+	}
+}
@@ -1,9 +1,11 @@
 package tests
 
 import (
+	"context"
 	"math/rand"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -204,3 +206,281 @@ func TestAdvancedMapRandom(t *testing.T) {
 		return
 	}
 }
+
+func TestSafeEMapTTL(t *testing.T) {
+	m := ssg.NewSafeEMap[string, int]()
+	m.SetExpiration(time.Hour)
+	m.SetInterval(2 * time.Second)
+	m.SetSliding(false)
+
+	m.SetWithTTL("short", 1, 50*time.Millisecond)
+	m.Set("long", 2)
+
+	remaining, ok := m.GetRemainingTime("short")
+	if !ok || remaining > 50*time.Millisecond {
+		t.Error("Expected at most 50ms for short, got:", remaining, ok)
+		return
+	}
+
+	remaining, _ = m.GetRemainingTime("long")
+	if remaining < 59*time.Minute {
+		t.Error("Expected about an hour for long, got:", remaining)
+		return
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	// non-sliding reads shouldn't refresh the lifetime.
+	_ = m.Get("short")
+	m.DoCheck()
+
+	if m.Exists("short") {
+		t.Error("Expected short to be expired")
+		return
+	}
+
+	if !m.Exists("long") {
+		t.Error("Expected long to still exist")
+		return
+	}
+
+	if _, ok := m.GetRemainingTime("short"); ok {
+		t.Error("Expected no remaining time for a deleted key")
+		return
+	}
+
+	if key, _ := m.GetRandomKey(); key != "long" {
+		t.Error("Expected long as the only random key, got:", key)
+		return
+	}
+}
+
+func TestSafeEMapSliding(t *testing.T) {
+	m := ssg.NewSafeEMap[string, int]()
+	m.SetExpiration(time.Hour)
+	m.SetInterval(2 * time.Second)
+
+	if !m.IsSliding() {
+		t.Error("Expected sliding to be enabled by default")
+		return
+	}
+
+	m.SetWithTTL("key", 1, 80*time.Millisecond)
+	for i := 0; i < 4; i++ {
+		time.Sleep(40 * time.Millisecond)
+		if m.GetValue("key") != 1 {
+			t.Error("Expected sliding read to keep key alive")
+			return
+		}
+		m.DoCheck()
+	}
+
+	if !m.Exists("key") {
+		t.Error("Expected key to still exist")
+		return
+	}
+}
+
+func TestSafeEMapChecking(t *testing.T) {
+	m := ssg.NewSafeEMap[int, int]()
+	m.SetExpiration(time.Hour)
+
+	expiredChan := make(chan int, 16)
+	m.SetOnExpired(func(key int, value int) {
+		expiredChan <- key
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m.EnableCheckingWithContext(ctx)
+	if !m.IsChecking() {
+		t.Error("Expected the checker loop to be running")
+		return
+	}
+
+	m.SetWithTTL(1, 1, 30*time.Millisecond)
+	m.SetWithTTL(2, 2, 10*time.Millisecond)
+	m.Set(3, 3)
+
+	for _, expected := range []int{2, 1} {
+		select {
+		case key := <-expiredChan:
+			if key != expected {
+				t.Error("Expected", expected, "to expire, got:", key)
+				return
+			}
+		case <-time.After(time.Second):
+			t.Error("Timed out waiting for", expected, "to expire")
+			return
+		}
+	}
+
+	if m.Length() != 1 || !m.Exists(3) {
+		t.Error("Expected only 3 to remain, got:", m.ToNormalMap())
+		return
+	}
+
+	cancel()
+	for i := 0; i < 100 && m.IsChecking(); i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	if m.IsChecking() {
+		t.Error("Expected the checker loop to stop after cancel")
+		return
+	}
+
+	m.EnableChecking()
+	_ = m.Close()
+	if m.IsChecking() {
+		t.Error("Expected the checker loop to stop after Close")
+		return
+	}
+}
+
+// computeMap is the set of atomic operations shared by the safe maps.
+type computeMap interface {
+	GetOrAdd(key int, value *int) (*int, bool)
+	GetOrCompute(key int, fn func() *int) (*int, bool)
+	Compute(key int, fn func(oldValue *int, exists bool) (*int, bool)) (*int, bool)
+	CompareAndSwap(key int, oldValue, newValue *int) bool
+	LoadAndDelete(key int) (*int, bool)
+	GetValue(key int) int
+	Exists(key int) bool
+}
+
+func TestMapComputeOperations(t *testing.T) {
+	maps := map[string]computeMap{
+		"SafeMap":        ssg.NewSafeMap[int, int](),
+		"AdvancedMap":    ssg.NewAdvancedMap[int, int](),
+		"SafeEMap":       ssg.NewSafeEMap[int, int](),
+		"ShardedSafeMap": ssg.NewShardedSafeMap[int, int](4),
+	}
+
+	for name, m := range maps {
+		testMapCompute(t, name, m)
+	}
+}
+
+func testMapCompute(t *testing.T, name string, m computeMap) {
+	const workers = 16
+	const iterations = 200
+
+	var computed, swapped, deleted int64
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				m.GetOrCompute(1, func() *int {
+					atomic.AddInt64(&computed, 1)
+					value := 0
+					return &value
+				})
+
+				m.Compute(2, func(oldValue *int, exists bool) (*int, bool) {
+					value := 1
+					if exists {
+						value = *oldValue + 1
+					}
+					return &value, true
+				})
+
+				for {
+					current, _ := m.GetOrAdd(3, new(int))
+					next := *current + 1
+					if m.CompareAndSwap(3, current, &next) {
+						atomic.AddInt64(&swapped, 1)
+						break
+					}
+				}
+
+				if j == 0 {
+					if _, loaded := m.LoadAndDelete(4); loaded {
+						atomic.AddInt64(&deleted, 1)
+					}
+				}
+			}
+		}()
+	}
+
+	m.GetOrAdd(4, new(int))
+	wg.Wait()
+
+	if computed != 1 {
+		t.Error(name, "expected GetOrCompute to call fn once, got:", computed)
+	}
+
+	if m.GetValue(2) != workers*iterations {
+		t.Error(name, "expected Compute to count to", workers*iterations, "got:", m.GetValue(2))
+	}
+
+	if swapped != workers*iterations || m.GetValue(3) != workers*iterations {
+		t.Error(name, "expected all swaps to be applied, got:", swapped, m.GetValue(3))
+	}
+
+	if deleted > 1 || m.Exists(4) != (deleted == 0) {
+		t.Error(name, "expected LoadAndDelete to delete the key at most once, got:", deleted)
+	}
+
+	value, ok := m.Compute(2, func(oldValue *int, exists bool) (*int, bool) {
+		return nil, false
+	})
+	if value != nil || ok || m.Exists(2) {
+		t.Error(name, "expected Compute to remove the key when keep is false")
+	}
+}
+
+// fillSafeEMap adds `count` long-living values to a new SafeEMap.
+func fillSafeEMap(count int) *ssg.SafeEMap[int, int] {
+	m := ssg.NewSafeEMap[int, int]()
+	m.SetExpiration(time.Hour)
+	for i := 0; i < count; i++ {
+		m.Set(i, i)
+	}
+
+	return m
+}
+
+// BenchmarkSafeEMapDoCheck1M measures how long a check holds the lock on a map
+// with 1M values, when 1000 of them are due.
+func BenchmarkSafeEMapDoCheck1M(b *testing.B) {
+	const total = 1000000
+	m := fillSafeEMap(total)
+
+	var maxHold time.Duration
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for j := 0; j < 1000; j++ {
+			m.SetWithTTL(total+j, j, time.Nanosecond)
+		}
+		time.Sleep(time.Microsecond)
+		b.StartTimer()
+
+		start := time.Now()
+		m.DoCheck()
+		if hold := time.Since(start); hold > maxHold {
+			maxHold = hold
+		}
+	}
+
+	b.ReportMetric(float64(maxHold.Nanoseconds()), "max-ns/check")
+}
+
+// BenchmarkSafeEMapGetWhileExpiring1M measures reads on a map with 1M values
+// while the checker loop is removing the expiring values in the background.
+func BenchmarkSafeEMapGetWhileExpiring1M(b *testing.B) {
+	const total = 1000000
+	m := fillSafeEMap(total)
+	m.EnableChecking()
+	defer m.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%10 == 0 {
+			m.SetWithTTL(total+i, i, time.Millisecond)
+		}
+		_ = m.Get(i % total)
+	}
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/AnimeKaizoku/ssg/ssg"
+)
+
+func TestShardedSafeMap01(t *testing.T) {
+	m := ssg.NewShardedSafeMap[string, int](8)
+	if m.ShardCount() != 8 {
+		t.Error("Expected 8 shards, got:", m.ShardCount())
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 1000; i++ {
+		wg.Add(1)
+		go func(value int) {
+			defer wg.Done()
+			m.Set("key"+strconv.Itoa(value), value)
+		}(i)
+	}
+	wg.Wait()
+
+	if m.Length() != 1000 {
+		t.Error("Expected 1000 for m.Length(), got:", m.Length())
+		return
+	}
+
+	if m.GetValue("key500") != 500 {
+		t.Error("Expected 500 for key500, got:", m.GetValue("key500"))
+		return
+	}
+
+	m.SetDefault(-1)
+	m.Delete("key500")
+	if m.Exists("key500") || m.GetValue("key500") != -1 {
+		t.Error("Expected key500 to be deleted, got:", m.GetValue("key500"))
+		return
+	}
+
+	m.ForEach(func(key string, value *int) bool {
+		return *value%2 == 0
+	})
+	if m.Length() != 500 {
+		t.Error("Expected 500 after ForEach, got:", m.Length())
+		return
+	}
+
+	m.Disable()
+	m.Set("disabled", 1)
+	if m.Exists("disabled") || !m.IsDisabled() {
+		t.Error("Expected disabled map to ignore Set")
+		return
+	}
+
+	m.Enable()
+	m.Clear()
+	if !m.IsEmpty() {
+		t.Error("Expected empty map after Clear, got:", m.Length())
+		return
+	}
+}
+
+func TestShardedSafeMapHasher(t *testing.T) {
+	// a hasher sending everything to a single shard should still work.
+	m := ssg.NewShardedSafeMapWithHasher[int, int](4, func(key int) uint64 {
+		return 0
+	})
+
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+
+	if len(m.ToNormalMap()) != 100 || len(m.ToArray()) != 100 {
+		t.Error("Expected 100 values, got:", m.Length())
+		return
+	}
+}
+
+const benchmarkKeysCount = 1024
+
+func getBenchmarkKeys() []string {
+	keys := make([]string, benchmarkKeysCount)
+	for i := range keys {
+		keys[i] = "user" + strconv.Itoa(i)
+	}
+
+	return keys
+}
+
+// benchmarkMapContention runs a mixed workload of 90% reads and 10% writes
+// on the given map from all of the benchmark goroutines.
+func benchmarkMapContention(
+	b *testing.B,
+	get func(key string) *int,
+	set func(key string, value int),
+) {
+	keys := getBenchmarkKeys()
+	for i, key := range keys {
+		set(key, i)
+	}
+
+	b.SetParallelism(16)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%benchmarkKeysCount]
+			if i%10 == 0 {
+				set(key, i)
+			} else {
+				_ = get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkSafeMapContention(b *testing.B) {
+	m := ssg.NewSafeMap[string, int]()
+	benchmarkMapContention(b, m.Get, func(key string, value int) {
+		m.Add(key, &value)
+	})
+}
+
+func BenchmarkAdvancedMapContention(b *testing.B) {
+	m := ssg.NewAdvancedMap[string, int]()
+	benchmarkMapContention(b, m.Get, func(key string, value int) {
+		m.Add(key, &value)
+	})
+}
+
+func BenchmarkShardedSafeMapContention(b *testing.B) {
+	m := ssg.NewShardedSafeMap[string, int](ssg.DefaultShardCount)
+	benchmarkMapContention(b, m.Get, func(key string, value int) {
+		m.Add(key, &value)
+	})
+}
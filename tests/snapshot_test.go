@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AnimeKaizoku/ssg/ssg"
+)
+
+func TestMapSnapshots(t *testing.T) {
+	codecs := []ssg.SnapshotCodec{
+		ssg.JSONSnapshotCodec{},
+		ssg.GobSnapshotCodec{},
+	}
+
+	for _, codec := range codecs {
+		m1 := ssg.NewSafeMap[int, dummyStructType2]()
+		m1.Set(1, dummyStructType2{Name: "one", Id: 1})
+		m1.Set(2, dummyStructType2{Name: "two", Id: 2})
+
+		buf := new(bytes.Buffer)
+		if err := m1.SaveToWithCodec(buf, codec); err != nil {
+			t.Error(err)
+			return
+		}
+
+		m2 := ssg.NewAdvancedMap[int, dummyStructType2]()
+		if err := m2.LoadFromWithCodec(buf, codec); err != nil {
+			t.Error(err)
+			return
+		}
+
+		if m2.Length() != 2 || m2.GetValue(2).Name != "two" {
+			t.Error("Expected two values in m2, got:", m2.ToNormalMap())
+			return
+		}
+
+		if _, ok := m2.GetRandomKey(); !ok {
+			t.Error("Expected a random key from m2")
+			return
+		}
+	}
+}
+
+func TestSafeEMapSnapshot(t *testing.T) {
+	m1 := ssg.NewSafeEMap[string, int]()
+	m1.SetExpiration(time.Hour)
+	m1.Set("long", 1)
+	m1.SetWithTTL("short", 2, 20*time.Millisecond)
+
+	buf := new(bytes.Buffer)
+	if err := m1.SaveTo(buf); err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	m2 := ssg.NewSafeEMap[string, int]()
+	m2.SetExpiration(time.Hour)
+	if err := m2.LoadFrom(buf); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if m2.Exists("short") || !m2.Exists("long") {
+		t.Error("Expected only long to be loaded, got:", m2.ToNormalMap())
+		return
+	}
+
+	remaining, _ := m2.GetRemainingTime("long")
+	if remaining > time.Hour-40*time.Millisecond {
+		t.Error("Expected the time of long to be preserved, got:", remaining)
+		return
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	m1 := ssg.NewSafeMap[string, string]()
+	m1.Set("key", "value")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := ssg.StartAutoSnapshot(ctx, m1, path, 0, nil, nil)
+	if !errors.Is(err, ssg.ErrInvalidSnapshotInterval) {
+		t.Error("Expected ErrInvalidSnapshotInterval for a zero interval, got:", err)
+		cancel()
+		return
+	}
+
+	done, err := ssg.StartAutoSnapshot(ctx, m1, path, time.Hour, nil, func(err error) {
+		t.Error(err)
+	})
+	if err != nil {
+		t.Error(err)
+		cancel()
+		return
+	}
+
+	// cancelling the context should write the last snapshot.
+	cancel()
+	<-done
+
+	m2 := ssg.NewSafeMap[string, string]()
+	if err := ssg.LoadSnapshotFile(m2, path, nil); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if m2.GetValue("key") != "value" {
+		t.Error("Expected value for key, got:", m2.GetValue("key"))
+		return
+	}
+
+	matches, _ := filepath.Glob(path + ".tmp-*")
+	if len(matches) != 0 {
+		t.Error("Expected no temporary files to remain, got:", matches)
+		return
+	}
+}
+
+type dummyStructType2 struct {
+	Name string
+	Id   int
+}